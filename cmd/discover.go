@@ -0,0 +1,80 @@
+// cmd/discover.go
+package cmd
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+	criblawshelper "github.com/zamorofthat/cribl-storage-tool/pkg/aws"
+)
+
+// discoverCmd represents the discover-partitions command
+var discoverCmd = &cobra.Command{
+	Use:   "discover-partitions bucket[/prefix]",
+	Short: "Infer the partition layout of the objects in an S3 bucket",
+	Long: `A subcommand to infer how the objects under a bucket or prefix are partitioned. Key names are sampled
+and each directory level is classified as a time token, a key=value Hive segment, a fixed literal or a
+variable. The time coverage and the matching Cribl Search path expression are reported.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Retrieve flags
+		outputFormat, err := cmd.Flags().GetString("output")
+		if err != nil {
+			log.Fatalf("Error retrieving output flag: %v", err)
+		}
+		profile, err := cmd.Flags().GetString("profile")
+		if err != nil {
+			log.Fatalf("Error retrieving profile flag: %v", err)
+		}
+		region, err := cmd.Flags().GetString("region")
+		if err != nil {
+			log.Fatalf("Error retrieving region flag: %v", err)
+		}
+		sampleSize, err := cmd.Flags().GetInt("sample-size")
+		if err != nil {
+			log.Fatalf("Error retrieving sample-size flag: %v", err)
+		}
+		if sampleSize < 1 {
+			log.Fatalf("Flag --sample-size must be at least 1")
+		}
+
+		bucket, prefix := parseS3Path(args[0])
+		if bucket == "" {
+			log.Fatalf("Invalid path %q, expected bucket[/prefix]", args[0])
+		}
+
+		// Load AWS configuration
+		cfg, err := loadAWSConfig(profile, region)
+		if err != nil {
+			log.Fatalf("Unable to load AWS SDK config: %v", err)
+		}
+
+		// Initialize S3 client
+		s3Client := criblawshelper.NewS3Client(cfg)
+
+		layout, err := s3Client.DiscoverPartitions(bucket, prefix, sampleSize)
+		if err != nil {
+			log.Fatalf("Error discovering partitions: %v", err)
+		}
+
+		// Format and print the output
+		switch outputFormat {
+		case "json":
+			if err := layout.PrintJSON(); err != nil {
+				log.Fatalf("Error printing partition layout in JSON format: %v", err)
+			}
+		case "text":
+			fallthrough
+		default:
+			layout.PrintText()
+		}
+	},
+}
+
+func init() {
+	// Define flags specific to the discover-partitions command
+	discoverCmd.Flags().StringP("output", "o", "text", "Output format: text or json")
+	discoverCmd.Flags().StringP("profile", "p", "", "AWS profile to use for authentication (optional)")
+	discoverCmd.Flags().StringP("region", "r", "", "AWS region to target (optional)")
+	discoverCmd.Flags().Int("sample-size", criblawshelper.DefaultPartitionSampleSize, "Number of keys to sample from the start of the prefix")
+}
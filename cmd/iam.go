@@ -3,6 +3,7 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 
 	//     "io/ioutil"
 	"os"
@@ -27,103 +28,80 @@ func init() {
 	iamCmd.AddCommand(iamSetupCmd)
 }
 
-func parseWorkerArn(arn string) (accountID, workspace, workergroup string, err error) {
-	// Expected format: arn:aws:iam::ACCOUNT:role/WORKSPACE-WORKERGROUP
-	parts := strings.Split(arn, ":")
-	if len(parts) != 6 {
-		return "", "", "", fmt.Errorf("invalid ARN format")
-	}
-
-	// Extract account ID
-	accountID = parts[4]
-
-	// Extract workspace and workergroup
-	roleParts := strings.Split(parts[5], "/")
-	if len(roleParts) != 2 {
-		return "", "", "", fmt.Errorf("invalid role format in ARN")
-	}
-
-	// Split the role name into workspace and workergroup
-	nameComponents := strings.Split(roleParts[1], "-")
-	if len(nameComponents) != 2 {
-		return "", "", "", fmt.Errorf("invalid role name format, expected workspace-workergroup")
-	}
-
-	workspace = nameComponents[0]
-	workergroup = nameComponents[1]
-
-	return accountID, workspace, workergroup, nil
-}
-
 var iamSetupCmd = &cobra.Command{
 	Use:   "setup",
 	Short: "Setup IAM role for cross-account access",
 	Long:  `A subcommand to setup IAM roles with trust relationships and necessary policies.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		logger := zerolog.New(os.Stdout).
+		logger := zerolog.New(os.Stderr).
 			With().
 			Timestamp().
 			Str("command", "iam_setup").
 			Logger()
 
-		// Get the worker ARN if provided
-		workerArn, err := cmd.Flags().GetString("cribl-worker-arn")
+		principals := resolveTrustedPrincipals(cmd, logger)
+
+		roleName, err := cmd.Flags().GetString("role")
 		if err != nil {
-			logger.Fatal().Err(err).Msg("error retrieving cribl-worker-arn flag")
+			logger.Fatal().Err(err).Msg("error retrieving role flag")
 		}
 
-		var trustedAccountID, workspace, workergroup string
+		externalID, err := cmd.Flags().GetString("external-id")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving external-id flag")
+		}
 
-		if workerArn != "" {
-			// Parse the worker ARN
-			trustedAccountID, workspace, workergroup, err = parseWorkerArn(workerArn)
-			if err != nil {
-				logger.Fatal().Err(err).Str("arn", workerArn).Msg("failed to parse worker ARN")
-			}
-			logger.Info().
-				Str("account_id", trustedAccountID).
-				Str("workspace", workspace).
-				Str("workergroup", workergroup).
-				Msg("parsed worker ARN")
-		} else {
-			// Use individual flags if ARN not provided
-			trustedAccountID, err = cmd.Flags().GetString("account")
-			if err != nil {
-				logger.Fatal().Err(err).Msg("error retrieving account flag")
-			}
-			workspace, err = cmd.Flags().GetString("workspace")
-			if err != nil {
-				logger.Fatal().Err(err).Msg("error retrieving workspace flag")
-			}
-			workergroup, err = cmd.Flags().GetString("workergroup")
-			if err != nil {
-				logger.Fatal().Err(err).Msg("error retrieving workergroup flag")
-			}
+		generateExternalID, err := cmd.Flags().GetBool("generate-external-id")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving generate-external-id flag")
 		}
 
-		roleName, err := cmd.Flags().GetString("role")
+		allowEmptyExternalID, err := cmd.Flags().GetBool("allow-empty-external-id")
 		if err != nil {
-			logger.Fatal().Err(err).Msg("error retrieving role flag")
+			logger.Fatal().Err(err).Msg("error retrieving allow-empty-external-id flag")
 		}
 
-		externalID, err := cmd.Flags().GetString("external-id")
+		externalIDParameter, err := cmd.Flags().GetString("external-id-ssm-parameter")
 		if err != nil {
-			logger.Fatal().Err(err).Msg("error retrieving external-id flag")
+			logger.Fatal().Err(err).Msg("error retrieving external-id-ssm-parameter flag")
+		}
+
+		externalIDSecret, err := cmd.Flags().GetString("external-id-secret")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving external-id-secret flag")
+		}
+
+		// Generate a random external ID when none was given
+		if generateExternalID {
+			externalID, err = criblawshelper.GenerateExternalID()
+			if err != nil {
+				logger.Fatal().Err(err).Msg("error generating external ID")
+			}
+			logger.Info().Msg("generated external ID")
+		}
+		if externalID == "" && !allowEmptyExternalID {
+			logger.Fatal().Msg("an external ID is required, pass --external-id or --generate-external-id (or --allow-empty-external-id to omit it)")
 		}
 
 		action, err := cmd.Flags().GetString("action")
 		if err != nil {
 			logger.Fatal().Err(err).Msg("error retrieving action flag")
 		}
+		if err := criblawshelper.ValidateAction(action); err != nil {
+			logger.Fatal().Err(err).Msg("invalid action flag")
+		}
 
-		bucketNames, err := cmd.Flags().GetStringSlice("bucket")
+		mergePrincipals, err := cmd.Flags().GetBool("merge-principals")
 		if err != nil {
-			logger.Fatal().Err(err).Msg("error retrieving bucket flag")
+			logger.Fatal().Err(err).Msg("error retrieving merge-principals flag")
 		}
 
-		bucketFile, err := cmd.Flags().GetString("bucket-file")
+		mode, err := cmd.Flags().GetString("mode")
 		if err != nil {
-			logger.Fatal().Err(err).Msg("error retrieving bucket-file flag")
+			logger.Fatal().Err(err).Msg("error retrieving mode flag")
+		}
+		if err := criblawshelper.ValidateMode(mode); err != nil {
+			logger.Fatal().Err(err).Msg("invalid mode flag")
 		}
 
 		profile, err := cmd.Flags().GetString("profile")
@@ -136,51 +114,94 @@ var iamSetupCmd = &cobra.Command{
 			logger.Fatal().Err(err).Msg("error retrieving region flag")
 		}
 
-		// Handle bucket-file if provided
-		if bucketFile != "" {
-			data, err := os.ReadFile(bucketFile)
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving dry-run flag")
+		}
+
+		planOutput, err := cmd.Flags().GetString("plan-output")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving plan-output flag")
+		}
+		if planOutput != "text" && planOutput != "json" {
+			logger.Fatal().Str("plan_output", planOutput).Msg("invalid plan-output, expected text or json")
+		}
+
+		tagEntries, err := cmd.Flags().GetStringArray("tag")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving tag flag")
+		}
+		tags := make(map[string]string, len(tagEntries))
+		for _, entry := range tagEntries {
+			key, value, err := criblawshelper.ParseTag(entry)
 			if err != nil {
-				logger.Fatal().Err(err).Str("file", bucketFile).Msg("error reading bucket file")
+				logger.Fatal().Err(err).Msg("invalid tag flag")
 			}
+			tags[key] = value
+		}
 
-			// Try to parse as JSON first
-			var fileBuckets []string
-			jsonErr := json.Unmarshal(data, &fileBuckets)
-			if jsonErr == nil {
-				// Successfully parsed as simple JSON array
-				bucketNames = append(bucketNames, fileBuckets...)
-				logger.Info().Strs("buckets", fileBuckets).Msg("loaded buckets from JSON array file")
-			} else {
-				// Try to parse as array of objects with name field
-				var bucketObjects []struct {
-					Name string `json:"name"`
-				}
-				jsonErr = json.Unmarshal(data, &bucketObjects)
-				if jsonErr == nil {
-					// Successfully parsed as array of objects
-					for _, obj := range bucketObjects {
-						bucketNames = append(bucketNames, obj.Name)
-					}
-					logger.Info().Strs("buckets", bucketNames).Msg("loaded buckets from JSON objects file")
-				} else {
-					// If both JSON parsing attempts failed, try to parse as plain text (one bucket per line)
-					lines := strings.Split(string(data), "\n")
-					for _, line := range lines {
-						line = strings.TrimSpace(line)
-						if line != "" {
-							bucketNames = append(bucketNames, line)
-						}
-					}
-					logger.Info().Strs("buckets", bucketNames).Msg("loaded buckets from text file")
-				}
+		permissionsBoundary, err := cmd.Flags().GetString("permissions-boundary")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving permissions-boundary flag")
+		}
+
+		rolePath, err := cmd.Flags().GetString("path")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving path flag")
+		}
+
+		maxSessionDuration, err := cmd.Flags().GetInt32("max-session-duration")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving max-session-duration flag")
+		}
+
+		description, err := cmd.Flags().GetString("description")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving description flag")
+		}
+
+		outputFormat, err := cmd.Flags().GetString("output-format")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving output-format flag")
+		}
+		if outputFormat != "" {
+			if err := criblawshelper.ValidateExportFormat(outputFormat); err != nil {
+				logger.Fatal().Err(err).Msg("invalid output-format flag")
 			}
 		}
 
-		// Enforce that at least one of --bucket or --bucket-file is provided
-		if len(bucketNames) == 0 {
-			logger.Fatal().Msg("at least one bucket name must be provided using the --bucket flag or --bucket-file flag")
+		outputFile, err := cmd.Flags().GetString("output-file")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving output-file flag")
 		}
 
+		kmsKeyARNs, err := cmd.Flags().GetStringSlice("kms-key-arn")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving kms-key-arn flag")
+		}
+
+		detectKMS, err := cmd.Flags().GetBool("detect-kms")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving detect-kms flag")
+		}
+
+		// Templates are rendered without calling AWS unless detection is asked for
+		if outputFormat != "" && !cmd.Flags().Changed("detect-kms") {
+			detectKMS = false
+		}
+
+		printKeyPolicy, err := cmd.Flags().GetBool("print-key-policy")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving print-key-policy flag")
+		}
+
+		compactWildcards, err := cmd.Flags().GetBool("compact-wildcards")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving compact-wildcards flag")
+		}
+
+		bucketNames := bucketNamesFromFlags(cmd, logger)
+
 		// Load AWS configuration
 		cfg, err := utils.LoadAWSConfig(cmd.Context(), profile, region, logger)
 		if err != nil {
@@ -193,29 +214,384 @@ var iamSetupCmd = &cobra.Command{
 		// Initialize IAM client with logger
 		iamClient := criblawshelper.NewIAMClient(cfg, logger)
 
+		// Detect the SSE-KMS keys used by the buckets unless disabled
+		if detectKMS {
+			detected := detectBucketKMSKeys(criblawshelper.NewS3Client(cfg), bucketNames, logger)
+			kmsKeyARNs = appendUnique(kmsKeyARNs, detected...)
+		}
+
+		// Desired state of the role and its policies
+		opts := criblawshelper.SetupOptions{
+			RoleName:             roleName,
+			Principals:           principals,
+			ExternalID:           externalID,
+			AllowEmptyExternalID: allowEmptyExternalID,
+			Action:               action,
+			BucketNames:          bucketNames,
+			KMSKeyARNs:           kmsKeyARNs,
+			Mode:                 mode,
+			MergePrincipals:      mergePrincipals,
+			CompactWildcards:     compactWildcards,
+			Tags:                 tags,
+			ToolVersion:          version,
+			Path:                 rolePath,
+			Description:          description,
+			MaxSessionDuration:   maxSessionDuration,
+			PermissionsBoundary:  permissionsBoundary,
+		}
+
+		// Render a template for IaC pipelines instead of calling AWS
+		if outputFormat != "" {
+			template, err := iamClient.ExportSetup(opts, outputFormat)
+			if err != nil {
+				logger.Fatal().Err(err).Msg("error exporting IAM setup")
+			}
+			if outputFile == "" {
+				fmt.Println(string(template))
+				return
+			}
+			if err := os.WriteFile(outputFile, template, 0644); err != nil {
+				logger.Fatal().Err(err).Str("file", outputFile).Msg("error writing template")
+			}
+			logger.Info().Str("file", outputFile).Str("format", outputFormat).Msg("wrote IAM setup template")
+			return
+		}
+
+		// In dry-run mode only compute and print the changes, no writes are made
+		if dryRun {
+			plan, err := iamClient.PlanTrustRelationship(opts)
+			if err != nil {
+				logger.Fatal().Err(err).Msg("error planning IAM trust relationship")
+			}
+			switch planOutput {
+			case "json":
+				if err := plan.PrintJSON(); err != nil {
+					logger.Fatal().Err(err).Msg("error printing plan in JSON format")
+				}
+			default:
+				plan.PrintText()
+			}
+			if printKeyPolicy {
+				// Keep stdout to the plan alone when it is parsed as JSON
+				out := os.Stdout
+				if planOutput == "json" {
+					out = os.Stderr
+				}
+				printKMSKeyPolicy(out, iamClient, roleName, action, kmsKeyARNs, logger)
+			}
+			return
+		}
+
+		// Persist the external ID before the trust policy requires it, so a
+		// failed write cannot leave the role trusting an ID nobody has. The
+		// setup is planned first so invalid input never reaches the store, and
+		// the previous value is put back if the setup fails.
+		restoreExternalID := func() error { return nil }
+		if externalIDParameter != "" || externalIDSecret != "" {
+			if _, err := iamClient.PlanTrustRelationship(opts); err != nil {
+				logger.Fatal().Err(err).Msg("error planning IAM trust relationship")
+			}
+			store := criblawshelper.NewExternalIDStore(cfg, logger)
+			restoreExternalID, err = store.Put(criblawshelper.ExternalIDTargets{
+				Parameter: externalIDParameter,
+				Secret:    externalIDSecret,
+			}, roleName, externalID)
+			if err != nil {
+				logger.Fatal().Err(err).Msg("error storing external ID")
+			}
+		} else if generateExternalID {
+			// Shown before any change, since a failed setup may not roll back cleanly
+			fmt.Printf("Generated external ID: %s\n", externalID)
+		}
+
 		// Setup Trust Relationship and Policies
-		err = iamClient.SetupTrustRelationship(roleName, trustedAccountID, externalID, workspace, workergroup, action, bucketNames)
+		err = iamClient.SetupTrustRelationship(opts)
 		if err != nil {
+			if restoreErr := restoreExternalID(); restoreErr != nil {
+				logger.Error().Err(restoreErr).Msg("error restoring the previously stored external ID")
+			}
 			logger.Fatal().Err(err).Msg("error setting up IAM trust relationship")
 		}
 
 		logger.Info().Msg("IAM trust relationship setup completed successfully")
+
+		if printKeyPolicy {
+			printKMSKeyPolicy(os.Stdout, iamClient, roleName, action, kmsKeyARNs, logger)
+		}
 	},
 }
 
+// resolveTrustedPrincipals returns the principals from every --cribl-worker-arn
+// and, when no ARN is given or --account is set with an explicit --workspace or
+// --workergroup, from the --workspace and --workergroup flags. Workspaces and worker groups are paired by position; a
+// single value is paired with every value of the other flag.
+func resolveTrustedPrincipals(cmd *cobra.Command, logger zerolog.Logger) []criblawshelper.TrustedPrincipal {
+	var principals []criblawshelper.TrustedPrincipal
+
+	workerArns, err := cmd.Flags().GetStringSlice("cribl-worker-arn")
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error retrieving cribl-worker-arn flag")
+	}
+
+	workspaces, err := cmd.Flags().GetStringSlice("workspace")
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error retrieving workspace flag")
+	}
+
+	// An explicit --workspace tells where a hyphenated workspace name ends
+	var workspaceHints []string
+	if cmd.Flags().Changed("workspace") {
+		workspaceHints = workspaces
+	}
+
+	for _, workerArn := range workerArns {
+		// Parse the worker ARN
+		principal, err := criblawshelper.ParseCriblPrincipalARN(workerArn, workspaceHints...)
+		if err != nil {
+			logger.Fatal().Err(err).Str("arn", workerArn).Msg("failed to parse worker ARN")
+		}
+		logger.Info().
+			Str("account_id", principal.AccountID).
+			Str("workspace", principal.Workspace).
+			Str("workergroup", principal.Workergroup).
+			Msg("parsed worker ARN")
+		principals = append(principals, principal)
+	}
+
+	trustedAccountID, err := cmd.Flags().GetString("account")
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error retrieving account flag")
+	}
+	if len(workerArns) > 0 {
+		if trustedAccountID == "" {
+			return principals
+		}
+		// Only trust workspaces and worker groups that were asked for, never
+		// the flag defaults next to the worker ARNs
+		if !cmd.Flags().Changed("workspace") && !cmd.Flags().Changed("workergroup") {
+			logger.Fatal().Msg("--account with --cribl-worker-arn requires --workspace or --workergroup to name the extra principals")
+		}
+	}
+
+	// Use individual flags if ARN not provided
+	workergroups, err := cmd.Flags().GetStringSlice("workergroup")
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error retrieving workergroup flag")
+	}
+
+	count := len(workspaces)
+	if len(workergroups) > count {
+		count = len(workergroups)
+	}
+	if len(workspaces) != count && len(workspaces) != 1 || len(workergroups) != count && len(workergroups) != 1 {
+		logger.Fatal().
+			Strs("workspaces", workspaces).
+			Strs("workergroups", workergroups).
+			Msg("--workspace and --workergroup must be given the same number of times, or one of them once")
+	}
+	for i := 0; i < count; i++ {
+		principals = append(principals, criblawshelper.TrustedPrincipal{
+			AccountID:   trustedAccountID,
+			Workspace:   workspaces[min(i, len(workspaces)-1)],
+			Workergroup: workergroups[min(i, len(workergroups)-1)],
+		})
+	}
+	return principals
+}
+
+// bucketNamesFromFlags returns the entries from --bucket and --bucket-file and
+// exits if neither provided any
+func bucketNamesFromFlags(cmd *cobra.Command, logger zerolog.Logger) []string {
+	bucketNames, err := cmd.Flags().GetStringSlice("bucket")
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error retrieving bucket flag")
+	}
+
+	bucketFile, err := cmd.Flags().GetString("bucket-file")
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error retrieving bucket-file flag")
+	}
+
+	// Handle bucket-file if provided
+	if bucketFile != "" {
+		fileBuckets, err := readBucketFile(bucketFile)
+		if err != nil {
+			logger.Fatal().Err(err).Str("file", bucketFile).Msg("error reading bucket file")
+		}
+		bucketNames = append(bucketNames, fileBuckets...)
+		logger.Info().Strs("buckets", fileBuckets).Msg("loaded buckets from file")
+	}
+
+	// Enforce that at least one of --bucket or --bucket-file is provided
+	if len(bucketNames) == 0 {
+		logger.Fatal().Msg("at least one bucket name must be provided using the --bucket flag or --bucket-file flag")
+	}
+	return bucketNames
+}
+
+// readBucketFile reads bucket entries from a JSON array of names, a JSON array of
+// objects with name and optional prefix fields, or plain text with one entry per line
+func readBucketFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Try to parse as JSON first
+	var bucketNames []string
+	if err := json.Unmarshal(data, &bucketNames); err == nil {
+		return bucketNames, nil
+	}
+
+	// Try to parse as array of objects with name and optional prefix fields
+	var bucketObjects []struct {
+		Name   string `json:"name"`
+		Prefix string `json:"prefix"`
+	}
+	if err := json.Unmarshal(data, &bucketObjects); err == nil {
+		for _, obj := range bucketObjects {
+			if obj.Prefix != "" {
+				bucketNames = append(bucketNames, obj.Name+"/"+obj.Prefix)
+			} else {
+				bucketNames = append(bucketNames, obj.Name)
+			}
+		}
+		return bucketNames, nil
+	}
+
+	// If both JSON parsing attempts failed, try to parse as plain text (one bucket per line)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			bucketNames = append(bucketNames, line)
+		}
+	}
+	return bucketNames, nil
+}
+
+// detectBucketKMSKeys returns the default SSE-KMS key ARNs of the given buckets.
+// Buckets encrypted with an alias or the AWS managed key are reported, since
+// those cannot be granted to a cross-account role. Buckets whose encryption
+// cannot be read are reported and skipped, so detection never blocks setup.
+func detectBucketKMSKeys(s3Client *criblawshelper.S3Client, bucketNames []string, logger zerolog.Logger) []string {
+	grants, err := criblawshelper.ParseBucketGrants(bucketNames)
+	if err != nil {
+		// Invalid entries are reported by setup itself
+		return nil
+	}
+
+	var keyARNs []string
+	seen := make(map[string]bool)
+	for _, grant := range grants {
+		if seen[grant.Bucket] {
+			continue
+		}
+		seen[grant.Bucket] = true
+
+		keyID, err := s3Client.GetBucketKMSKey(grant.Bucket)
+		switch {
+		case err != nil:
+			logger.Warn().Err(err).
+				Str("bucket", grant.Bucket).
+				Msg("unable to read default encryption of bucket, pass --kms-key-arn if it uses SSE-KMS")
+			continue
+		case keyID == "":
+			logger.Info().Str("bucket", grant.Bucket).Msg("bucket is not encrypted with SSE-KMS")
+			continue
+		case keyID == criblawshelper.AWSManagedS3KeyAlias:
+			logger.Warn().
+				Str("bucket", grant.Bucket).
+				Msg("bucket is encrypted with the AWS managed aws/s3 KMS key, which cross-account roles cannot use; switch its default encryption to a customer managed key")
+			continue
+		}
+		if _, err := criblawshelper.ParseKMSKeyARN(keyID); err != nil {
+			logger.Warn().Err(err).
+				Str("bucket", grant.Bucket).
+				Str("kms_key", keyID).
+				Msg("unable to grant detected KMS key, pass its ARN with --kms-key-arn")
+			continue
+		}
+		logger.Info().Str("bucket", grant.Bucket).Str("kms_key_arn", keyID).Msg("detected bucket KMS key")
+		keyARNs = appendUnique(keyARNs, keyID)
+	}
+	return keyARNs
+}
+
+// printKMSKeyPolicy prints the statement to add to each key policy to out. The
+// role ARN is a placeholder when the role does not exist yet.
+func printKMSKeyPolicy(out io.Writer, iamClient *criblawshelper.IAMClient, roleName, action string, kmsKeyARNs []string, logger zerolog.Logger) {
+	if len(kmsKeyARNs) == 0 {
+		logger.Warn().Msg("no KMS keys to print a key policy for")
+		return
+	}
+
+	roleARN, err := iamClient.GetRoleARN(roleName)
+	if err != nil {
+		roleARN = fmt.Sprintf("arn:%s:iam::<ACCOUNT_ID>:role/%s", iamClient.Partition(), roleName)
+	}
+
+	statement, err := criblawshelper.KMSKeyPolicyStatement(roleARN, action, kmsKeyARNs)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error creating KMS key policy statement")
+	}
+
+	fmt.Fprintf(out, "Add this statement to the key policy of: %s\n", strings.Join(kmsKeyARNs, ", "))
+	fmt.Fprintln(out, statement)
+}
+
+// appendUnique appends the values not already present in list
+func appendUnique(list []string, values ...string) []string {
+	for _, value := range values {
+		found := false
+		for _, existing := range list {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
 func init() {
 	// Define flags specific to the setup command
-	iamSetupCmd.Flags().String("cribl-worker-arn", "", "Cribl worker ARN (e.g., arn:aws:iam::ACCOUNT:role/WORKSPACE-WORKERGROUP)")
+	iamSetupCmd.Flags().StringSlice("cribl-worker-arn", []string{}, "Cribl worker ARN (e.g., arn:aws:iam::ACCOUNT:role/WORKSPACE-WORKERGROUP) (can specify multiple)")
 	iamSetupCmd.Flags().StringP("role", "r", "CrossAccountAccessRole", "Name of the IAM role to create or update")
 	iamSetupCmd.Flags().StringP("account", "a", "", "AWS Account ID to trust (required if --cribl-worker-arn not provided)")
-	iamSetupCmd.Flags().StringP("external-id", "e", "", "External ID for the trust relationship (optional)")
-	iamSetupCmd.Flags().StringP("workspace", "w", "main", "Workspace name (default: main)")
-	iamSetupCmd.Flags().StringP("workergroup", "g", "default", "Worker group name (default: default)")
-	iamSetupCmd.Flags().StringP("action", "s", "search", "Action type for the IAM role (default: search)")
-	iamSetupCmd.Flags().StringSliceP("bucket", "b", []string{}, "Name of the S3 bucket to grant access (can specify multiple)")
+	iamSetupCmd.Flags().StringP("external-id", "e", "", "External ID for the trust relationship")
+	iamSetupCmd.Flags().Bool("generate-external-id", false, "Generate a random external ID for the trust relationship")
+	iamSetupCmd.Flags().Bool("allow-empty-external-id", false, "Allow setup without an external ID; the trust policy then has no external ID condition")
+	iamSetupCmd.Flags().String("external-id-ssm-parameter", "", "Name of an SSM SecureString parameter to store the external ID in (optional)")
+	iamSetupCmd.Flags().String("external-id-secret", "", "Name of a Secrets Manager secret to store the external ID in (optional)")
+	iamSetupCmd.Flags().StringSliceP("workspace", "w", []string{"main"}, "Workspace name (default: main) (can specify multiple); with --cribl-worker-arn, marks where a hyphenated workspace name ends")
+	iamSetupCmd.Flags().StringSliceP("workergroup", "g", []string{"default"}, "Worker group name, paired with --workspace (default: default) (can specify multiple)")
+	iamSetupCmd.Flags().Bool("merge-principals", false, "Keep the principals already trusted by an existing role")
+	iamSetupCmd.Flags().StringP("action", "s", "search", "Action type for the IAM role: search, send, collect or replay (default: search)")
+	iamSetupCmd.Flags().StringSliceP("bucket", "b", []string{}, "Name of the S3 bucket to grant access, optionally as bucket/prefix/ (can specify multiple)")
 	iamSetupCmd.Flags().StringP("bucket-file", "f", "", "Path to JSON file containing S3 bucket names (optional)")
+	iamSetupCmd.Flags().String("mode", "replace", "How to apply the buckets to an existing role: add, remove or replace")
 	iamSetupCmd.Flags().StringP("profile", "p", "", "AWS profile to use for authentication (optional)")
 	iamSetupCmd.Flags().StringP("region", "z", "", "AWS region to target (optional)")
+	iamSetupCmd.Flags().StringSlice("kms-key-arn", []string{}, "ARN of a KMS key used to encrypt the buckets (can specify multiple)")
+	iamSetupCmd.Flags().Bool("detect-kms", true, "Detect the default SSE-KMS key of each bucket and grant access to it; with --output-format only when set explicitly")
+	iamSetupCmd.Flags().Bool("print-key-policy", false, "Print the statement to add to the KMS key policy")
+	iamSetupCmd.Flags().String("permissions-boundary", "", "ARN of a managed policy to set as the role's permissions boundary (optional)")
+	iamSetupCmd.Flags().String("path", "", "Path of the role, e.g. /cribl/ (an existing role must already use it; default: /)")
+	iamSetupCmd.Flags().Int32("max-session-duration", 0, "Maximum session duration of the role in seconds, 3600 to 43200 (default: 3600)")
+	iamSetupCmd.Flags().String("description", "", "Description of the role (default: \"Role for cross-account access to S3\")")
+	iamSetupCmd.Flags().StringArray("tag", []string{}, "Tag to add to the role and its policies as key=value (can specify multiple)")
+	iamSetupCmd.Flags().Bool("compact-wildcards", false, "Collapse buckets sharing a name prefix into wildcards when the grants exceed IAM policy limits")
+	iamSetupCmd.Flags().Bool("dry-run", false, "Show the IAM changes that would be made without applying them")
+	iamSetupCmd.Flags().String("plan-output", "text", "Output format for --dry-run: text or json")
+	iamSetupCmd.Flags().String("output-format", "", "Render the setup as a terraform, cloudformation or json template instead of applying it")
+	iamSetupCmd.Flags().String("output-file", "", "File to write the --output-format template to (default: stdout)")
+
+	iamSetupCmd.MarkFlagsMutuallyExclusive("external-id", "generate-external-id")
+	iamSetupCmd.MarkFlagsMutuallyExclusive("generate-external-id", "allow-empty-external-id")
+	iamSetupCmd.MarkFlagsMutuallyExclusive("output-format", "dry-run")
 
 	// Only require account if cribl-worker-arn is not provided
 	// iamSetupCmd.MarkFlagRequired("account")
@@ -0,0 +1,83 @@
+// cmd/iam_describe.go
+package cmd
+
+import (
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	criblawshelper "github.com/zamorofthat/cribl-storage-tool/pkg/aws"
+	"github.com/zamorofthat/cribl-storage-tool/pkg/utils"
+)
+
+var iamDescribeCmd = &cobra.Command{
+	Use:   "describe",
+	Short: "Describe a role in Cribl terms",
+	Long: `A subcommand to decode a role's trust policy and its inline and attached managed policies into a
+summary of which Cribl workspaces and worker groups can assume it, whether an external ID is
+enforced, and which buckets, prefixes and actions it grants. Useful when debugging a failing dataset.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := zerolog.New(os.Stderr).
+			With().
+			Timestamp().
+			Str("command", "iam_describe").
+			Logger()
+
+		roleName, err := cmd.Flags().GetString("role")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving role flag")
+		}
+
+		outputFormat, err := cmd.Flags().GetString("output")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving output flag")
+		}
+
+		profile, err := cmd.Flags().GetString("profile")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving profile flag")
+		}
+
+		region, err := cmd.Flags().GetString("region")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving region flag")
+		}
+
+		// Load AWS configuration
+		cfg, err := utils.LoadAWSConfig(cmd.Context(), profile, region, logger)
+		if err != nil {
+			logger.Fatal().Err(err).
+				Str("profile", profile).
+				Str("region", region).
+				Msg("unable to load AWS SDK config")
+		}
+
+		iamClient := criblawshelper.NewIAMClient(cfg, logger)
+
+		description, err := iamClient.DescribeRole(roleName)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error describing IAM role")
+		}
+
+		switch outputFormat {
+		case "json":
+			if err := description.PrintJSON(); err != nil {
+				logger.Fatal().Err(err).Msg("error printing description in JSON format")
+			}
+		default:
+			description.PrintText()
+		}
+	},
+}
+
+func init() {
+	iamCmd.AddCommand(iamDescribeCmd)
+
+	iamDescribeCmd.Flags().StringP("role", "r", "", "Name of the IAM role to describe")
+	iamDescribeCmd.Flags().StringP("output", "o", "text", "Output format: text or json")
+	iamDescribeCmd.Flags().StringP("profile", "p", "", "AWS profile to use for authentication (optional)")
+	iamDescribeCmd.Flags().StringP("region", "z", "", "AWS region to target (optional)")
+
+	iamDescribeCmd.MarkFlagRequired("role")
+}
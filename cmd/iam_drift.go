@@ -0,0 +1,115 @@
+// cmd/iam_drift.go
+package cmd
+
+import (
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	criblawshelper "github.com/zamorofthat/cribl-storage-tool/pkg/aws"
+	"github.com/zamorofthat/cribl-storage-tool/pkg/utils"
+)
+
+var iamDriftCmd = &cobra.Command{
+	Use:   "drift",
+	Short: "Detect drift between a role and its desired configuration",
+	Long: `A subcommand to compare an IAM role's trust policy and S3 policy with the desired buckets,
+worker ARN and external ID. Exits non-zero when drift is found, for use in scheduled compliance jobs.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := zerolog.New(os.Stderr).
+			With().
+			Timestamp().
+			Str("command", "iam_drift").
+			Logger()
+
+		principals := resolveTrustedPrincipals(cmd, logger)
+
+		roleName, err := cmd.Flags().GetString("role")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving role flag")
+		}
+
+		externalID, err := cmd.Flags().GetString("external-id")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving external-id flag")
+		}
+
+		action, err := cmd.Flags().GetString("action")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving action flag")
+		}
+		if err := criblawshelper.ValidateAction(action); err != nil {
+			logger.Fatal().Err(err).Msg("invalid action flag")
+		}
+
+		outputFormat, err := cmd.Flags().GetString("output")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving output flag")
+		}
+
+		profile, err := cmd.Flags().GetString("profile")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving profile flag")
+		}
+
+		region, err := cmd.Flags().GetString("region")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving region flag")
+		}
+
+		bucketNames := bucketNamesFromFlags(cmd, logger)
+
+		// Load AWS configuration
+		cfg, err := utils.LoadAWSConfig(cmd.Context(), profile, region, logger)
+		if err != nil {
+			logger.Fatal().Err(err).
+				Str("profile", profile).
+				Str("region", region).
+				Msg("unable to load AWS SDK config")
+		}
+
+		iamClient := criblawshelper.NewIAMClient(cfg, logger)
+
+		report, err := iamClient.DetectDrift(criblawshelper.SetupOptions{
+			RoleName:    roleName,
+			Principals:  principals,
+			ExternalID:  externalID,
+			Action:      action,
+			BucketNames: bucketNames,
+		})
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error detecting drift")
+		}
+
+		switch outputFormat {
+		case "json":
+			if err := report.PrintJSON(); err != nil {
+				logger.Fatal().Err(err).Msg("error printing report in JSON format")
+			}
+		default:
+			report.PrintText()
+		}
+
+		if report.HasDrift() {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	iamCmd.AddCommand(iamDriftCmd)
+
+	iamDriftCmd.Flags().StringSlice("cribl-worker-arn", []string{}, "Cribl worker ARN (e.g., arn:aws:iam::ACCOUNT:role/WORKSPACE-WORKERGROUP) (can specify multiple)")
+	iamDriftCmd.Flags().StringP("role", "r", "CrossAccountAccessRole", "Name of the IAM role to check")
+	iamDriftCmd.Flags().StringP("account", "a", "", "AWS Account ID that should be trusted (required if --cribl-worker-arn not provided)")
+	iamDriftCmd.Flags().StringP("external-id", "e", "", "External ID the trust relationship should require (optional, not checked if empty)")
+	iamDriftCmd.Flags().StringSliceP("workspace", "w", []string{"main"}, "Workspace name (default: main) (can specify multiple); with --cribl-worker-arn, marks where a hyphenated workspace name ends")
+	iamDriftCmd.Flags().StringSliceP("workergroup", "g", []string{"default"}, "Worker group name, paired with --workspace (default: default) (can specify multiple)")
+	iamDriftCmd.Flags().StringP("action", "s", "search", "Action type for the IAM role: search, send, collect or replay (default: search)")
+	iamDriftCmd.Flags().StringSliceP("bucket", "b", []string{}, "Name of the S3 bucket that should be granted, optionally as bucket/prefix/ (can specify multiple)")
+	iamDriftCmd.Flags().StringP("bucket-file", "f", "", "Path to JSON file containing S3 bucket names (optional)")
+	iamDriftCmd.Flags().StringP("output", "o", "text", "Output format: text or json")
+	iamDriftCmd.Flags().StringP("profile", "p", "", "AWS profile to use for authentication (optional)")
+	iamDriftCmd.Flags().StringP("region", "z", "", "AWS region to target (optional)")
+}
@@ -0,0 +1,114 @@
+// cmd/iam_import.go
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	criblawshelper "github.com/zamorofthat/cribl-storage-tool/pkg/aws"
+	"github.com/zamorofthat/cribl-storage-tool/pkg/utils"
+)
+
+var iamImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Adopt an existing role into the tool's management",
+	Long: `A subcommand to inspect a hand-made IAM role, recognise its Cribl principals, extract the buckets
+from its policies and tag it as managed by cribl-storage-tool. Prints the iam setup command that
+reproduces the role and optionally writes its buckets to a bucket file.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := zerolog.New(os.Stderr).
+			With().
+			Timestamp().
+			Str("command", "iam_import").
+			Logger()
+
+		roleName, err := cmd.Flags().GetString("role")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving role flag")
+		}
+
+		bucketFile, err := cmd.Flags().GetString("bucket-file")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving bucket-file flag")
+		}
+
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving dry-run flag")
+		}
+
+		force, err := cmd.Flags().GetBool("force")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving force flag")
+		}
+
+		outputFormat, err := cmd.Flags().GetString("output")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving output flag")
+		}
+
+		profile, err := cmd.Flags().GetString("profile")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving profile flag")
+		}
+
+		region, err := cmd.Flags().GetString("region")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving region flag")
+		}
+
+		// Load AWS configuration
+		cfg, err := utils.LoadAWSConfig(cmd.Context(), profile, region, logger)
+		if err != nil {
+			logger.Fatal().Err(err).
+				Str("profile", profile).
+				Str("region", region).
+				Msg("unable to load AWS SDK config")
+		}
+
+		iamClient := criblawshelper.NewIAMClient(cfg, logger)
+
+		report, err := iamClient.ImportRole(roleName, !dryRun, force)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error importing IAM role")
+		}
+
+		// Write the buckets in the JSON format accepted by --bucket-file
+		if bucketFile != "" {
+			data, err := json.MarshalIndent(report.Buckets, "", "  ")
+			if err != nil {
+				logger.Fatal().Err(err).Msg("error encoding bucket file")
+			}
+			if err := os.WriteFile(bucketFile, append(data, '\n'), 0644); err != nil {
+				logger.Fatal().Err(err).Str("file", bucketFile).Msg("error writing bucket file")
+			}
+			logger.Info().Str("file", bucketFile).Int("bucket_count", len(report.Buckets)).Msg("wrote bucket file")
+		}
+
+		switch outputFormat {
+		case "json":
+			if err := report.PrintJSON(); err != nil {
+				logger.Fatal().Err(err).Msg("error printing report in JSON format")
+			}
+		default:
+			report.PrintText(bucketFile)
+		}
+	},
+}
+
+func init() {
+	iamCmd.AddCommand(iamImportCmd)
+
+	iamImportCmd.Flags().StringP("role", "r", "", "Name of the IAM role to import")
+	iamImportCmd.Flags().StringP("bucket-file", "f", "", "Path to write the role's buckets to as a JSON bucket file (optional)")
+	iamImportCmd.Flags().Bool("dry-run", false, "Inspect the role without tagging it")
+	iamImportCmd.Flags().Bool("force", false, "Tag the role even if it trusts principals that are not Cribl roles")
+	iamImportCmd.Flags().StringP("output", "o", "text", "Output format: text or json")
+	iamImportCmd.Flags().StringP("profile", "p", "", "AWS profile to use for authentication (optional)")
+	iamImportCmd.Flags().StringP("region", "z", "", "AWS region to target (optional)")
+
+	iamImportCmd.MarkFlagRequired("role")
+}
@@ -0,0 +1,91 @@
+// cmd/iam_list.go
+package cmd
+
+import (
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	criblawshelper "github.com/zamorofthat/cribl-storage-tool/pkg/aws"
+	"github.com/zamorofthat/cribl-storage-tool/pkg/utils"
+)
+
+var iamListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the Cribl cross-account roles in the account",
+	Long: `A subcommand to inventory the IAM roles that trust Cribl principals or are tagged as managed by
+cribl-storage-tool. Prints each role's trusted account, workspace, worker group, action, whether it
+requires an external ID and the buckets it grants access to.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := zerolog.New(os.Stderr).
+			With().
+			Timestamp().
+			Str("command", "iam_list").
+			Logger()
+
+		outputFormat, err := cmd.Flags().GetString("output")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving output flag")
+		}
+
+		pathPrefix, err := cmd.Flags().GetString("path-prefix")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving path-prefix flag")
+		}
+
+		profile, err := cmd.Flags().GetString("profile")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving profile flag")
+		}
+
+		region, err := cmd.Flags().GetString("region")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving region flag")
+		}
+
+		switch outputFormat {
+		case "text", "json", "csv":
+		default:
+			logger.Fatal().Str("output", outputFormat).Msg("invalid output format, expected text, json or csv")
+		}
+
+		// Load AWS configuration
+		cfg, err := utils.LoadAWSConfig(cmd.Context(), profile, region, logger)
+		if err != nil {
+			logger.Fatal().Err(err).
+				Str("profile", profile).
+				Str("region", region).
+				Msg("unable to load AWS SDK config")
+		}
+
+		iamClient := criblawshelper.NewIAMClient(cfg, logger)
+
+		summaries, err := iamClient.ListCriblRoles(pathPrefix)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error listing Cribl roles")
+		}
+
+		switch outputFormat {
+		case "json":
+			if err := criblawshelper.PrintRoleSummariesJSON(summaries); err != nil {
+				logger.Fatal().Err(err).Msg("error printing roles in JSON format")
+			}
+		case "csv":
+			if err := criblawshelper.PrintRoleSummariesCSV(summaries); err != nil {
+				logger.Fatal().Err(err).Msg("error printing roles in CSV format")
+			}
+		default:
+			criblawshelper.PrintRoleSummariesText(summaries)
+		}
+	},
+}
+
+func init() {
+	iamCmd.AddCommand(iamListCmd)
+
+	iamListCmd.Flags().StringP("output", "o", "text", "Output format: text, json or csv")
+	iamListCmd.Flags().String("path-prefix", "", "Only consider roles whose path starts with this prefix (optional)")
+	iamListCmd.Flags().StringP("profile", "p", "", "AWS profile to use for authentication (optional)")
+	iamListCmd.Flags().StringP("region", "z", "", "AWS region to target (optional)")
+}
@@ -0,0 +1,137 @@
+// cmd/iam_rotate.go
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	criblawshelper "github.com/zamorofthat/cribl-storage-tool/pkg/aws"
+	"github.com/zamorofthat/cribl-storage-tool/pkg/utils"
+)
+
+var iamRotateExternalIDCmd = &cobra.Command{
+	Use:   "rotate-external-id",
+	Short: "Rotate the external ID of a role without downtime",
+	Long: `A subcommand to rotate the external ID required by a role's trust policy in two steps.
+The first run adds a new external ID next to the current one and prints it, so the Cribl side
+can be updated while running jobs keep working. Once Cribl uses the new ID, run again with
+--complete and --external-id set to the new ID to remove the old one.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := zerolog.New(os.Stderr).
+			With().
+			Timestamp().
+			Str("command", "iam_rotate_external_id").
+			Logger()
+
+		roleName, err := cmd.Flags().GetString("role")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving role flag")
+		}
+
+		externalID, err := cmd.Flags().GetString("external-id")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving external-id flag")
+		}
+
+		complete, err := cmd.Flags().GetBool("complete")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving complete flag")
+		}
+
+		externalIDParameter, err := cmd.Flags().GetString("external-id-ssm-parameter")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving external-id-ssm-parameter flag")
+		}
+
+		externalIDSecret, err := cmd.Flags().GetString("external-id-secret")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving external-id-secret flag")
+		}
+
+		profile, err := cmd.Flags().GetString("profile")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving profile flag")
+		}
+
+		region, err := cmd.Flags().GetString("region")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving region flag")
+		}
+
+		if complete && externalID == "" {
+			logger.Fatal().Msg("--complete requires --external-id set to the new external ID")
+		}
+
+		// Load AWS configuration
+		cfg, err := utils.LoadAWSConfig(cmd.Context(), profile, region, logger)
+		if err != nil {
+			logger.Fatal().Err(err).
+				Str("profile", profile).
+				Str("region", region).
+				Msg("unable to load AWS SDK config")
+		}
+
+		iamClient := criblawshelper.NewIAMClient(cfg, logger)
+
+		if complete {
+			if err := iamClient.CompleteExternalIDRotation(roleName, externalID); err != nil {
+				logger.Fatal().Err(err).Msg("error completing external ID rotation")
+			}
+			logger.Info().Msg("external ID rotation completed successfully")
+			return
+		}
+
+		// Generate the new external ID unless one was given
+		if externalID == "" {
+			externalID, err = criblawshelper.GenerateExternalID()
+			if err != nil {
+				logger.Fatal().Err(err).Msg("error generating external ID")
+			}
+		}
+
+		// Check the rotation can start before anything is written, so a
+		// refused rotation leaves the stored external ID untouched
+		update, err := iamClient.PlanExternalIDRotation(roleName, externalID)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error starting external ID rotation")
+		}
+
+		// Store the new external ID before the role trusts it, and put the
+		// previous value back if the trust policy cannot be updated
+		store := criblawshelper.NewExternalIDStore(cfg, logger)
+		restore, err := store.Put(criblawshelper.ExternalIDTargets{
+			Parameter: externalIDParameter,
+			Secret:    externalIDSecret,
+		}, roleName, externalID)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error storing external ID")
+		}
+
+		if err := iamClient.ApplyTrustPolicyUpdate(update); err != nil {
+			if restoreErr := restore(); restoreErr != nil {
+				logger.Error().Err(restoreErr).Msg("error restoring the previously stored external ID")
+			}
+			logger.Fatal().Err(err).Msg("error starting external ID rotation")
+		}
+
+		fmt.Printf("New external ID: %s\n", externalID)
+		fmt.Println("Update the external ID in Cribl, then run again with --complete --external-id <new ID>.")
+	},
+}
+
+func init() {
+	iamCmd.AddCommand(iamRotateExternalIDCmd)
+
+	iamRotateExternalIDCmd.Flags().StringP("role", "r", "", "Name of the IAM role to rotate the external ID of")
+	iamRotateExternalIDCmd.Flags().StringP("external-id", "e", "", "New external ID (generated if not given when starting a rotation)")
+	iamRotateExternalIDCmd.Flags().Bool("complete", false, "Remove every external ID except --external-id from the trust policy")
+	iamRotateExternalIDCmd.Flags().String("external-id-ssm-parameter", "", "Name of an SSM SecureString parameter to store the new external ID in (optional)")
+	iamRotateExternalIDCmd.Flags().String("external-id-secret", "", "Name of a Secrets Manager secret to store the new external ID in (optional)")
+	iamRotateExternalIDCmd.Flags().StringP("profile", "p", "", "AWS profile to use for authentication (optional)")
+	iamRotateExternalIDCmd.Flags().StringP("region", "z", "", "AWS region to target (optional)")
+
+	iamRotateExternalIDCmd.MarkFlagRequired("role")
+}
@@ -0,0 +1,75 @@
+// cmd/iam_teardown.go
+package cmd
+
+import (
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	criblawshelper "github.com/zamorofthat/cribl-storage-tool/pkg/aws"
+	"github.com/zamorofthat/cribl-storage-tool/pkg/utils"
+)
+
+var iamTeardownCmd = &cobra.Command{
+	Use:   "teardown",
+	Short: "Remove an IAM role created by setup",
+	Long: `A subcommand to delete an IAM role created by "iam setup", including its inline policies,
+managed policy attachments and instance profile memberships. Roles not created by this tool are
+refused unless --force is given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := zerolog.New(os.Stderr).
+			With().
+			Timestamp().
+			Str("command", "iam_teardown").
+			Logger()
+
+		roleName, err := cmd.Flags().GetString("role")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving role flag")
+		}
+
+		force, err := cmd.Flags().GetBool("force")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving force flag")
+		}
+
+		profile, err := cmd.Flags().GetString("profile")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving profile flag")
+		}
+
+		region, err := cmd.Flags().GetString("region")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving region flag")
+		}
+
+		// Load AWS configuration
+		cfg, err := utils.LoadAWSConfig(cmd.Context(), profile, region, logger)
+		if err != nil {
+			logger.Fatal().Err(err).
+				Str("profile", profile).
+				Str("region", region).
+				Msg("unable to load AWS SDK config")
+		}
+
+		iamClient := criblawshelper.NewIAMClient(cfg, logger)
+
+		if err := iamClient.TeardownRole(roleName, force); err != nil {
+			logger.Fatal().Err(err).Msg("error tearing down IAM role")
+		}
+
+		logger.Info().Msg("IAM role teardown completed successfully")
+	},
+}
+
+func init() {
+	iamCmd.AddCommand(iamTeardownCmd)
+
+	iamTeardownCmd.Flags().StringP("role", "r", "", "Name of the IAM role to delete")
+	iamTeardownCmd.Flags().Bool("force", false, "Delete the role even if it was not created by cribl-storage-tool")
+	iamTeardownCmd.Flags().StringP("profile", "p", "", "AWS profile to use for authentication (optional)")
+	iamTeardownCmd.Flags().StringP("region", "z", "", "AWS region to target (optional)")
+
+	iamTeardownCmd.MarkFlagRequired("role")
+}
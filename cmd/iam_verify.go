@@ -0,0 +1,108 @@
+// cmd/iam_verify.go
+package cmd
+
+import (
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	criblawshelper "github.com/zamorofthat/cribl-storage-tool/pkg/aws"
+	"github.com/zamorofthat/cribl-storage-tool/pkg/utils"
+)
+
+var iamVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Simulate the role's access to its buckets",
+	Long: `A subcommand to check that an IAM role grants every S3 action the chosen Cribl action needs,
+using the IAM policy simulator. Prints a per-bucket pass/fail matrix and exits non-zero on any denial.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := zerolog.New(os.Stderr).
+			With().
+			Timestamp().
+			Str("command", "iam_verify").
+			Logger()
+
+		roleName, err := cmd.Flags().GetString("role")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving role flag")
+		}
+
+		action, err := cmd.Flags().GetString("action")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving action flag")
+		}
+		if err := criblawshelper.ValidateAction(action); err != nil {
+			logger.Fatal().Err(err).Msg("invalid action flag")
+		}
+
+		kmsKeyARNs, err := cmd.Flags().GetStringSlice("kms-key-arn")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving kms-key-arn flag")
+		}
+
+		outputFormat, err := cmd.Flags().GetString("output")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving output flag")
+		}
+
+		profile, err := cmd.Flags().GetString("profile")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving profile flag")
+		}
+
+		region, err := cmd.Flags().GetString("region")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error retrieving region flag")
+		}
+
+		bucketNames := bucketNamesFromFlags(cmd, logger)
+
+		grants, err := criblawshelper.ParseBucketGrants(bucketNames)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("invalid bucket entry")
+		}
+
+		// Load AWS configuration
+		cfg, err := utils.LoadAWSConfig(cmd.Context(), profile, region, logger)
+		if err != nil {
+			logger.Fatal().Err(err).
+				Str("profile", profile).
+				Str("region", region).
+				Msg("unable to load AWS SDK config")
+		}
+
+		iamClient := criblawshelper.NewIAMClient(cfg, logger)
+
+		report, err := iamClient.VerifyRole(roleName, action, grants, kmsKeyARNs)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error verifying IAM role")
+		}
+
+		switch outputFormat {
+		case "json":
+			if err := report.PrintJSON(); err != nil {
+				logger.Fatal().Err(err).Msg("error printing report in JSON format")
+			}
+		default:
+			report.PrintText()
+		}
+
+		if !report.Passed() {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	iamCmd.AddCommand(iamVerifyCmd)
+
+	iamVerifyCmd.Flags().StringP("role", "r", "CrossAccountAccessRole", "Name of the IAM role to verify")
+	iamVerifyCmd.Flags().StringP("action", "s", "search", "Action type the role is used for: search, send, collect or replay (default: search)")
+	iamVerifyCmd.Flags().StringSliceP("bucket", "b", []string{}, "Name of the S3 bucket to check, optionally as bucket/prefix/ (can specify multiple)")
+	iamVerifyCmd.Flags().StringP("bucket-file", "f", "", "Path to JSON file containing S3 bucket names (optional)")
+	iamVerifyCmd.Flags().StringSlice("kms-key-arn", []string{}, "ARN of a KMS key the role must be able to use (can specify multiple)")
+	iamVerifyCmd.Flags().StringP("output", "o", "text", "Output format: text or json")
+	iamVerifyCmd.Flags().StringP("profile", "p", "", "AWS profile to use for authentication (optional)")
+	iamVerifyCmd.Flags().StringP("region", "z", "", "AWS region to target (optional)")
+}
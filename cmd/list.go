@@ -47,6 +47,26 @@ var listCmd = &cobra.Command{
 		if err != nil {
 			log.Fatalf("Error retrieving bucket-file flag: %v", err)
 		}
+		prefix, err := cmd.Flags().GetString("prefix")
+		if err != nil {
+			log.Fatalf("Error retrieving prefix flag: %v", err)
+		}
+		bucketRegions, err := cmd.Flags().GetStringSlice("bucket-region")
+		if err != nil {
+			log.Fatalf("Error retrieving bucket-region flag: %v", err)
+		}
+		details, err := cmd.Flags().GetBool("details")
+		if err != nil {
+			log.Fatalf("Error retrieving details flag: %v", err)
+		}
+		concurrency, err := cmd.Flags().GetInt("concurrency")
+		if err != nil {
+			log.Fatalf("Error retrieving concurrency flag: %v", err)
+		}
+		size, err := cmd.Flags().GetBool("size")
+		if err != nil {
+			log.Fatalf("Error retrieving size flag: %v", err)
+		}
 
 		// Enforce mutual exclusivity between --filter, --regex, and --bucket-file
 		count := 0
@@ -62,6 +82,24 @@ var listCmd = &cobra.Command{
 		if count > 1 {
 			log.Fatalf("Flags --filter, --regex, and --bucket-file cannot be used together. Please use only one.")
 		}
+		if bucketFile != "" && (prefix != "" || len(bucketRegions) > 0) {
+			log.Fatalf("Flags --prefix and --bucket-region filter the account's buckets and cannot be used with --bucket-file.")
+		}
+
+		// The bucket list and the size report support different formats
+		if size {
+			switch outputFormat {
+			case "text", "json", "csv":
+			default:
+				log.Fatalf("Invalid output format %q with --size, expected text, json or csv", outputFormat)
+			}
+		} else {
+			switch outputFormat {
+			case "text", "json", "names":
+			default:
+				log.Fatalf("Invalid output format %q, expected text, json or names (csv requires --size)", outputFormat)
+			}
+		}
 
 		// Load AWS configuration
 		cfg, err := loadAWSConfig(profile, region)
@@ -72,8 +110,8 @@ var listCmd = &cobra.Command{
 		// Initialize S3 client
 		s3Client := criblawshelper.NewS3Client(cfg)
 
-		// Retrieve the list of buckets
-		buckets, err := s3Client.ListBuckets()
+		// Retrieve the list of buckets, filtered by prefix and region on the server side
+		buckets, err := s3Client.ListBuckets(prefix, bucketRegions)
 		if err != nil {
 			log.Fatalf("Error listing S3 buckets: %v", err)
 		}
@@ -114,6 +152,18 @@ var listCmd = &cobra.Command{
 			buckets = regexFilteredBuckets
 		}
 
+		// Report sizes and object counts from CloudWatch instead of the bucket list
+		if size {
+			stats := criblawshelper.NewStatsClient(cfg).GetBucketStats(buckets, concurrency)
+			printBucketStats(stats, outputFormat)
+			return
+		}
+
+		// Look up region, encryption and the other bucket settings if requested
+		if details {
+			buckets = s3Client.DescribeBuckets(buckets, concurrency)
+		}
+
 		// Format and print the output
 		switch outputFormat {
 		case "json":
@@ -126,6 +176,10 @@ var listCmd = &cobra.Command{
 		case "text":
 			fallthrough
 		default:
+			if details {
+				s3Client.PrintBucketsDetails(buckets)
+				return
+			}
 			s3Client.PrintBucketsText(buckets)
 		}
 	},
@@ -196,10 +250,17 @@ func loadBucketsFromFile(filePath string) ([]criblawshelper.Bucket, error) {
 
 func init() {
 	// Define flags specific to the list command
-	listCmd.Flags().StringP("output", "o", "text", "Output format: text, json, or names")
+	listCmd.Flags().StringP("output", "o", "text", "Output format: text, json, or names (csv with --size)")
 	listCmd.Flags().StringP("profile", "p", "", "AWS profile to use for authentication (optional)")
 	listCmd.Flags().StringP("region", "r", "", "AWS region to target (optional)")
 	listCmd.Flags().StringP("filter", "f", "", "Filter bucket names containing the specified substring (optional)")
 	listCmd.Flags().StringP("regex", "x", "", "Filter bucket names matching the specified regular expression (optional)")
 	listCmd.Flags().StringP("bucket-file", "b", "", "Path to file containing S3 bucket names (optional)")
+	listCmd.Flags().String("prefix", "", "Only list buckets whose names start with the specified prefix (optional)")
+	listCmd.Flags().StringSlice("bucket-region", []string{}, "Only list buckets in the specified region (can specify multiple)")
+	listCmd.Flags().BoolP("details", "d", false, "Show region, creation date, encryption, versioning, Object Lock and public access block of each bucket")
+	listCmd.Flags().Bool("size", false, "Show the size and object count of each bucket from CloudWatch storage metrics")
+	listCmd.Flags().Int("concurrency", criblawshelper.DefaultDetailsConcurrency, "Number of buckets to inspect at once with --details or --size")
+
+	listCmd.MarkFlagsMutuallyExclusive("details", "size")
 }
@@ -0,0 +1,95 @@
+// cmd/ls.go
+package cmd
+
+import (
+	"log"
+	"strings"
+
+	"github.com/spf13/cobra"
+	criblawshelper "github.com/zamorofthat/cribl-storage-tool/pkg/aws"
+)
+
+// lsCmd represents the ls command
+var lsCmd = &cobra.Command{
+	Use:   "ls bucket[/prefix]",
+	Short: "List the objects in an S3 bucket",
+	Long: `A subcommand to browse the objects in an S3 bucket. Keys are grouped by "/" into prefixes like
+directories, unless --recursive is set. Accepts bucket, bucket/prefix or s3://bucket/prefix.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Retrieve flags
+		outputFormat, err := cmd.Flags().GetString("output")
+		if err != nil {
+			log.Fatalf("Error retrieving output flag: %v", err)
+		}
+		profile, err := cmd.Flags().GetString("profile")
+		if err != nil {
+			log.Fatalf("Error retrieving profile flag: %v", err)
+		}
+		region, err := cmd.Flags().GetString("region")
+		if err != nil {
+			log.Fatalf("Error retrieving region flag: %v", err)
+		}
+		recursive, err := cmd.Flags().GetBool("recursive")
+		if err != nil {
+			log.Fatalf("Error retrieving recursive flag: %v", err)
+		}
+		maxKeys, err := cmd.Flags().GetInt("max-keys")
+		if err != nil {
+			log.Fatalf("Error retrieving max-keys flag: %v", err)
+		}
+		if maxKeys < 0 {
+			log.Fatalf("Flag --max-keys cannot be negative")
+		}
+
+		bucket, prefix := parseS3Path(args[0])
+		if bucket == "" {
+			log.Fatalf("Invalid path %q, expected bucket[/prefix]", args[0])
+		}
+
+		// Load AWS configuration
+		cfg, err := loadAWSConfig(profile, region)
+		if err != nil {
+			log.Fatalf("Unable to load AWS SDK config: %v", err)
+		}
+
+		// Initialize S3 client
+		s3Client := criblawshelper.NewS3Client(cfg)
+
+		listing, err := s3Client.ListObjects(bucket, prefix, recursive, maxKeys)
+		if err != nil {
+			log.Fatalf("Error listing objects: %v", err)
+		}
+
+		// Format and print the output
+		switch outputFormat {
+		case "json":
+			if err := listing.PrintJSON(); err != nil {
+				log.Fatalf("Error printing objects in JSON format: %v", err)
+			}
+		case "names":
+			listing.PrintNamesOnly()
+		case "text":
+			fallthrough
+		default:
+			listing.PrintText()
+		}
+	},
+}
+
+// parseS3Path splits bucket/prefix or s3://bucket/prefix into bucket and prefix.
+// The prefix is kept as given, so a partial key name matches like in S3.
+func parseS3Path(path string) (bucket, prefix string) {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "s3://")
+	bucket, prefix, _ = strings.Cut(path, "/")
+	return bucket, prefix
+}
+
+func init() {
+	// Define flags specific to the ls command
+	lsCmd.Flags().StringP("output", "o", "text", "Output format: text, json, or names")
+	lsCmd.Flags().StringP("profile", "p", "", "AWS profile to use for authentication (optional)")
+	lsCmd.Flags().StringP("region", "r", "", "AWS region to target (optional)")
+	lsCmd.Flags().BoolP("recursive", "R", false, "List every object under the prefix instead of grouping keys by \"/\"")
+	lsCmd.Flags().Int("max-keys", 0, "Maximum number of prefixes and objects to list (default all)")
+}
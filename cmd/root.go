@@ -5,11 +5,15 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// version is set at build time with -ldflags "-X github.com/zamorofthat/cribl-storage-tool/cmd.version=..."
+var version = "dev"
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-	Use:   "cribl-storage-tool",
-	Short: "A CLI tool to manage Cribl storage",
-	Long:  `Cribl Storage Tool is a CLI application to manage various Cribl storage resources.`,
+	Use:     "cribl-storage-tool",
+	Short:   "A CLI tool to manage Cribl storage",
+	Long:    `Cribl Storage Tool is a CLI application to manage various Cribl storage resources.`,
+	Version: version,
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
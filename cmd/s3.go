@@ -18,4 +18,13 @@ func init() {
 
 	// Add the list subcommand to the s3 command
 	s3Cmd.AddCommand(listCmd)
+
+	// Add the stats subcommand to the s3 command
+	s3Cmd.AddCommand(statsCmd)
+
+	// Add the ls subcommand to the s3 command
+	s3Cmd.AddCommand(lsCmd)
+
+	// Add the discover-partitions subcommand to the s3 command
+	s3Cmd.AddCommand(discoverCmd)
 }
\ No newline at end of file
@@ -0,0 +1,108 @@
+// cmd/stats.go
+package cmd
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+	criblawshelper "github.com/zamorofthat/cribl-storage-tool/pkg/aws"
+)
+
+// statsCmd represents the stats command
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the size and object count of S3 buckets",
+	Long: `A subcommand to report the size and object count of S3 buckets, with a breakdown per storage class,
+from the daily S3 storage metrics in CloudWatch. Useful to size a bucket before pointing Cribl Search at it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Retrieve flags
+		outputFormat, err := cmd.Flags().GetString("output")
+		if err != nil {
+			log.Fatalf("Error retrieving output flag: %v", err)
+		}
+		profile, err := cmd.Flags().GetString("profile")
+		if err != nil {
+			log.Fatalf("Error retrieving profile flag: %v", err)
+		}
+		region, err := cmd.Flags().GetString("region")
+		if err != nil {
+			log.Fatalf("Error retrieving region flag: %v", err)
+		}
+		bucketNames, err := cmd.Flags().GetStringSlice("bucket")
+		if err != nil {
+			log.Fatalf("Error retrieving bucket flag: %v", err)
+		}
+		prefix, err := cmd.Flags().GetString("prefix")
+		if err != nil {
+			log.Fatalf("Error retrieving prefix flag: %v", err)
+		}
+		bucketRegions, err := cmd.Flags().GetStringSlice("bucket-region")
+		if err != nil {
+			log.Fatalf("Error retrieving bucket-region flag: %v", err)
+		}
+		concurrency, err := cmd.Flags().GetInt("concurrency")
+		if err != nil {
+			log.Fatalf("Error retrieving concurrency flag: %v", err)
+		}
+
+		switch outputFormat {
+		case "text", "json", "csv":
+		default:
+			log.Fatalf("Invalid output format %q, expected text, json or csv", outputFormat)
+		}
+
+		// Load AWS configuration
+		cfg, err := loadAWSConfig(profile, region)
+		if err != nil {
+			log.Fatalf("Unable to load AWS SDK config: %v", err)
+		}
+
+		statsClient := criblawshelper.NewStatsClient(cfg)
+
+		// Report on the given buckets, or on every bucket in the account
+		var buckets []criblawshelper.Bucket
+		if len(bucketNames) > 0 {
+			for _, name := range bucketNames {
+				buckets = append(buckets, criblawshelper.Bucket{Name: name})
+			}
+		} else {
+			buckets, err = statsClient.S3.ListBuckets(prefix, bucketRegions)
+			if err != nil {
+				log.Fatalf("Error listing S3 buckets: %v", err)
+			}
+		}
+
+		stats := statsClient.GetBucketStats(buckets, concurrency)
+		printBucketStats(stats, outputFormat)
+	},
+}
+
+// printBucketStats prints bucket stats in text, json or csv format
+func printBucketStats(stats []criblawshelper.BucketStats, outputFormat string) {
+	switch outputFormat {
+	case "json":
+		if err := criblawshelper.PrintBucketStatsJSON(stats); err != nil {
+			log.Fatalf("Error printing bucket stats in JSON format: %v", err)
+		}
+	case "csv":
+		if err := criblawshelper.PrintBucketStatsCSV(stats); err != nil {
+			log.Fatalf("Error printing bucket stats in CSV format: %v", err)
+		}
+	default:
+		criblawshelper.PrintBucketStatsText(stats)
+	}
+}
+
+func init() {
+	// Define flags specific to the stats command
+	statsCmd.Flags().StringP("output", "o", "text", "Output format: text, json, or csv")
+	statsCmd.Flags().StringP("profile", "p", "", "AWS profile to use for authentication (optional)")
+	statsCmd.Flags().StringP("region", "r", "", "AWS region to target (optional)")
+	statsCmd.Flags().StringSliceP("bucket", "b", []string{}, "Name of a bucket to report on (can specify multiple, default all buckets)")
+	statsCmd.Flags().String("prefix", "", "Only report on buckets whose names start with the specified prefix (optional)")
+	statsCmd.Flags().StringSlice("bucket-region", []string{}, "Only report on buckets in the specified region (can specify multiple)")
+	statsCmd.Flags().Int("concurrency", criblawshelper.DefaultDetailsConcurrency, "Number of buckets to read metrics for at once")
+
+	statsCmd.MarkFlagsMutuallyExclusive("bucket", "prefix")
+	statsCmd.MarkFlagsMutuallyExclusive("bucket", "bucket-region")
+}
@@ -0,0 +1,115 @@
+// pkg/aws/arn.go
+package aws
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// AWS partitions a role can live in. Principals must be in the same partition
+// as the role that trusts them.
+const (
+	PartitionAWS      = "aws"
+	PartitionAWSCN    = "aws-cn"
+	PartitionAWSUSGov = "aws-us-gov"
+)
+
+// IAM and S3 naming limits
+const (
+	maxRoleNameLength   = 64
+	minBucketNameLength = 3
+	maxBucketNameLength = 63
+)
+
+var (
+	accountIDPattern  = regexp.MustCompile(`^[0-9]{12}$`)
+	roleNamePattern   = regexp.MustCompile(`^[\w+=,.@-]+$`)
+	bucketNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]*[a-z0-9]$`)
+	bucketCharPattern = regexp.MustCompile(`^[a-z0-9.-]+$`)
+)
+
+// PartitionForRegion returns the partition of an AWS region
+func PartitionForRegion(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return PartitionAWSCN
+	case strings.HasPrefix(region, "us-gov-"):
+		return PartitionAWSUSGov
+	default:
+		return PartitionAWS
+	}
+}
+
+// ValidatePartition returns an error if partition is not a supported partition
+func ValidatePartition(partition string) error {
+	switch partition {
+	case PartitionAWS, PartitionAWSCN, PartitionAWSUSGov:
+		return nil
+	default:
+		return fmt.Errorf("unsupported partition '%s', expected one of: %s, %s, %s",
+			partition, PartitionAWS, PartitionAWSCN, PartitionAWSUSGov)
+	}
+}
+
+// dnsSuffix returns the domain of the service endpoints in a partition
+func dnsSuffix(partition string) string {
+	if partition == PartitionAWSCN {
+		return "amazonaws.com.cn"
+	}
+	return "amazonaws.com"
+}
+
+// ValidateAccountID returns an error if id is not a 12-digit AWS account ID
+func ValidateAccountID(id string) error {
+	if !accountIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid account ID '%s': must be exactly 12 digits", id)
+	}
+	return nil
+}
+
+// ValidateRoleName checks a role name against the IAM naming rules
+func ValidateRoleName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("role name cannot be empty")
+	case len(name) > maxRoleNameLength:
+		return fmt.Errorf("invalid role name '%s': must be at most %d characters, got %d", name, maxRoleNameLength, len(name))
+	case !roleNamePattern.MatchString(name):
+		return fmt.Errorf("invalid role name '%s': only letters, digits and +=,.@_- are allowed", name)
+	}
+	return nil
+}
+
+// ValidateBucketName checks a bucket name against the S3 naming rules. Wildcards
+// are refused, so a bucket given by the user never grants more than one bucket;
+// wildcard grants read back from an existing policy are not validated.
+func ValidateBucketName(name string) error {
+	if strings.ContainsAny(name, "*?") {
+		return fmt.Errorf("invalid bucket name '%s': wildcards are not allowed, list each bucket", name)
+	}
+	if len(name) > maxBucketNameLength {
+		return fmt.Errorf("invalid bucket name '%s': must be at most %d characters", name, maxBucketNameLength)
+	}
+	if !bucketCharPattern.MatchString(name) {
+		return fmt.Errorf("invalid bucket name '%s': only lowercase letters, digits, dots and hyphens are allowed", name)
+	}
+	if strings.Contains(name, "..") {
+		return fmt.Errorf("invalid bucket name '%s': must not contain two adjacent dots", name)
+	}
+
+	switch {
+	case len(name) < minBucketNameLength:
+		return fmt.Errorf("invalid bucket name '%s': must be at least %d characters", name, minBucketNameLength)
+	case !bucketNamePattern.MatchString(name):
+		return fmt.Errorf("invalid bucket name '%s': must begin and end with a letter or digit", name)
+	case net.ParseIP(name) != nil:
+		return fmt.Errorf("invalid bucket name '%s': must not be formatted as an IP address", name)
+	case strings.HasPrefix(name, "xn--"), strings.HasPrefix(name, "sthree-"):
+		return fmt.Errorf("invalid bucket name '%s': the prefix is reserved", name)
+	case strings.HasSuffix(name, "-s3alias"), strings.HasSuffix(name, "--ol-s3"):
+		return fmt.Errorf("invalid bucket name '%s': the suffix is reserved", name)
+	}
+	return nil
+}
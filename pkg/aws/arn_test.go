@@ -0,0 +1,31 @@
+// pkg/aws/arn_test.go
+package aws
+
+import "testing"
+
+func TestValidateBucketName(t *testing.T) {
+	tests := []struct {
+		name    string
+		bucket  string
+		wantErr bool
+	}{
+		{name: "valid", bucket: "cribl-logs.us-east-1"},
+		{name: "wildcard only", bucket: "*", wantErr: true},
+		{name: "trailing wildcard", bucket: "acme-*", wantErr: true},
+		{name: "single character wildcard", bucket: "acme-?", wantErr: true},
+		{name: "too short", bucket: "ab", wantErr: true},
+		{name: "uppercase", bucket: "Cribl-Logs", wantErr: true},
+		{name: "adjacent dots", bucket: "cribl..logs", wantErr: true},
+		{name: "ip address", bucket: "192.168.1.1", wantErr: true},
+		{name: "reserved suffix", bucket: "cribl-s3alias", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateBucketName(tt.bucket)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateBucketName(%q) = %v, want error %v", tt.bucket, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+// pkg/aws/external_id.go
+package aws
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smtypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/rs/zerolog"
+)
+
+// externalIDBytes is the amount of randomness in a generated external ID
+const externalIDBytes = 32
+
+// GenerateExternalID returns a random external ID with 256 bits of entropy,
+// hex encoded so it only uses characters IAM accepts in sts:ExternalId
+func GenerateExternalID() (string, error) {
+	buf := make([]byte, externalIDBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate external ID: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// ExternalIDStore persists an external ID so the Cribl admin can retrieve it
+type ExternalIDStore struct {
+	ssm            *ssm.Client
+	secretsManager *secretsmanager.Client
+	logger         zerolog.Logger
+}
+
+// NewExternalIDStore initializes the SSM and Secrets Manager clients
+func NewExternalIDStore(cfg aws.Config, logger zerolog.Logger) *ExternalIDStore {
+	return &ExternalIDStore{
+		ssm:            ssm.NewFromConfig(cfg),
+		secretsManager: secretsmanager.NewFromConfig(cfg),
+		logger:         logger.With().Str("component", "external_id_store").Logger(),
+	}
+}
+
+// PutParameter writes the external ID to a SecureString SSM parameter,
+// overwriting any previous value
+func (s *ExternalIDStore) PutParameter(name, roleName, externalID string) error {
+	logger := s.logger.With().Str("parameter", name).Logger()
+
+	_, err := s.ssm.PutParameter(context.TODO(), &ssm.PutParameterInput{
+		Name:        aws.String(name),
+		Value:       aws.String(externalID),
+		Type:        ssmtypes.ParameterTypeSecureString,
+		Description: aws.String(fmt.Sprintf("External ID for IAM role %s", roleName)),
+		Overwrite:   aws.Bool(true),
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to store external ID in SSM")
+		return fmt.Errorf("failed to store external ID in SSM parameter '%s': %w", name, err)
+	}
+
+	logger.Info().Msg("stored external ID in SSM parameter")
+	return nil
+}
+
+// PutSecret writes the external ID to a Secrets Manager secret, creating the
+// secret if it does not exist yet
+func (s *ExternalIDStore) PutSecret(name, roleName, externalID string) error {
+	logger := s.logger.With().Str("secret", name).Logger()
+
+	_, err := s.secretsManager.CreateSecret(context.TODO(), &secretsmanager.CreateSecretInput{
+		Name:         aws.String(name),
+		SecretString: aws.String(externalID),
+		Description:  aws.String(fmt.Sprintf("External ID for IAM role %s", roleName)),
+		Tags: []smtypes.Tag{
+			{Key: aws.String(ManagedByTagKey), Value: aws.String(ManagedByTagValue)},
+		},
+	})
+	if err == nil {
+		logger.Info().Msg("created secret with external ID")
+		return nil
+	}
+
+	var exists *smtypes.ResourceExistsException
+	if !errors.As(err, &exists) {
+		logger.Error().Err(err).Msg("failed to create secret")
+		return fmt.Errorf("failed to store external ID in secret '%s': %w", name, err)
+	}
+
+	_, err = s.secretsManager.PutSecretValue(context.TODO(), &secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(name),
+		SecretString: aws.String(externalID),
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to update secret")
+		return fmt.Errorf("failed to store external ID in secret '%s': %w", name, err)
+	}
+
+	logger.Info().Msg("updated secret with external ID")
+	return nil
+}
+
+// ExternalIDTargets names the SSM parameter and Secrets Manager secret an
+// external ID is stored in. Either may be empty.
+type ExternalIDTargets struct {
+	Parameter string
+	Secret    string
+}
+
+// storedValue is the value a parameter or secret held before it was written
+type storedValue struct {
+	Value  string
+	Exists bool
+}
+
+// Put writes the external ID to every target and returns a function that puts
+// back the values they held before, deleting targets that did not exist. If a
+// write fails, the targets already written are restored before returning.
+func (s *ExternalIDStore) Put(targets ExternalIDTargets, roleName, externalID string) (func() error, error) {
+	var restores []func() error
+	restore := func() error {
+		var errs []error
+		for i := len(restores) - 1; i >= 0; i-- {
+			if err := restores[i](); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+	fail := func(err error) (func() error, error) {
+		if restoreErr := restore(); restoreErr != nil {
+			return nil, fmt.Errorf("%w; restoring the previous value failed: %v", err, restoreErr)
+		}
+		return nil, err
+	}
+
+	if targets.Parameter != "" {
+		previous, err := s.getParameter(targets.Parameter)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.PutParameter(targets.Parameter, roleName, externalID); err != nil {
+			return fail(err)
+		}
+		restores = append(restores, func() error {
+			if !previous.Exists {
+				return s.deleteParameter(targets.Parameter)
+			}
+			return s.PutParameter(targets.Parameter, roleName, previous.Value)
+		})
+	}
+
+	if targets.Secret != "" {
+		previous, err := s.getSecret(targets.Secret)
+		if err != nil {
+			return fail(err)
+		}
+		if err := s.PutSecret(targets.Secret, roleName, externalID); err != nil {
+			return fail(err)
+		}
+		restores = append(restores, func() error {
+			if !previous.Exists {
+				return s.deleteSecret(targets.Secret)
+			}
+			return s.PutSecret(targets.Secret, roleName, previous.Value)
+		})
+	}
+	return restore, nil
+}
+
+// getParameter returns the current value of an SSM parameter
+func (s *ExternalIDStore) getParameter(name string) (storedValue, error) {
+	out, err := s.ssm.GetParameter(context.TODO(), &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		var notFound *ssmtypes.ParameterNotFound
+		if errors.As(err, &notFound) {
+			return storedValue{}, nil
+		}
+		return storedValue{}, fmt.Errorf("failed to read SSM parameter '%s': %w", name, err)
+	}
+	return storedValue{Value: aws.ToString(out.Parameter.Value), Exists: true}, nil
+}
+
+func (s *ExternalIDStore) deleteParameter(name string) error {
+	_, err := s.ssm.DeleteParameter(context.TODO(), &ssm.DeleteParameterInput{
+		Name: aws.String(name),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete SSM parameter '%s': %w", name, err)
+	}
+	s.logger.Info().Str("parameter", name).Msg("deleted SSM parameter")
+	return nil
+}
+
+// getSecret returns the current value of a Secrets Manager secret
+func (s *ExternalIDStore) getSecret(name string) (storedValue, error) {
+	out, err := s.secretsManager.GetSecretValue(context.TODO(), &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(name),
+	})
+	if err != nil {
+		var notFound *smtypes.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return storedValue{}, nil
+		}
+		return storedValue{}, fmt.Errorf("failed to read secret '%s': %w", name, err)
+	}
+	return storedValue{Value: aws.ToString(out.SecretString), Exists: true}, nil
+}
+
+func (s *ExternalIDStore) deleteSecret(name string) error {
+	_, err := s.secretsManager.DeleteSecret(context.TODO(), &secretsmanager.DeleteSecretInput{
+		SecretId:                   aws.String(name),
+		ForceDeleteWithoutRecovery: aws.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete secret '%s': %w", name, err)
+	}
+	s.logger.Info().Str("secret", name).Msg("deleted secret")
+	return nil
+}
@@ -0,0 +1,156 @@
+// pkg/aws/grants.go
+package aws
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BucketGrant is access to a bucket, optionally limited to a key prefix
+type BucketGrant struct {
+	Bucket string
+	Prefix string
+}
+
+// ParseBucketGrant parses a "bucket" or "bucket/prefix/" entry. Prefixes are
+// normalised to end with a slash so a grant never matches sibling prefixes.
+func ParseBucketGrant(entry string) (BucketGrant, error) {
+	entry = strings.TrimSpace(entry)
+	entry = strings.TrimPrefix(entry, "s3://")
+
+	bucket, prefix, _ := strings.Cut(entry, "/")
+	if bucket == "" {
+		return BucketGrant{}, fmt.Errorf("invalid bucket entry '%s': bucket name is empty", entry)
+	}
+
+	prefix = strings.Trim(prefix, "/")
+	if strings.ContainsAny(prefix, "*?") {
+		return BucketGrant{}, fmt.Errorf("invalid bucket entry '%s': prefix cannot contain wildcards", entry)
+	}
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	return BucketGrant{Bucket: bucket, Prefix: prefix}, nil
+}
+
+// ParseBucketGrants parses a list of bucket entries, dropping duplicates and
+// prefix grants that are already covered by a whole-bucket grant.
+func ParseBucketGrants(entries []string) ([]BucketGrant, error) {
+	var grants []BucketGrant
+	wholeBuckets := make(map[string]bool)
+	seen := make(map[BucketGrant]bool)
+
+	for _, entry := range entries {
+		grant, err := ParseBucketGrant(entry)
+		if err != nil {
+			return nil, err
+		}
+		if seen[grant] {
+			continue
+		}
+		seen[grant] = true
+		if grant.Prefix == "" {
+			wholeBuckets[grant.Bucket] = true
+		}
+		grants = append(grants, grant)
+	}
+
+	result := make([]BucketGrant, 0, len(grants))
+	for _, grant := range grants {
+		if grant.Prefix != "" && wholeBuckets[grant.Bucket] {
+			continue
+		}
+		result = append(result, grant)
+	}
+	return result, nil
+}
+
+// String returns the grant in the "bucket" or "bucket/prefix/" form
+func (g BucketGrant) String() string {
+	if g.Prefix == "" {
+		return g.Bucket
+	}
+	return g.Bucket + "/" + g.Prefix
+}
+
+// BucketARN returns the ARN of the granted bucket in partition
+func (g BucketGrant) BucketARN(partition string) string {
+	return fmt.Sprintf("arn:%s:s3:::%s", partition, g.Bucket)
+}
+
+// ObjectARN returns the ARN in partition matching every object covered by the grant
+func (g BucketGrant) ObjectARN(partition string) string {
+	return fmt.Sprintf("arn:%s:s3:::%s/%s*", partition, g.Bucket, g.Prefix)
+}
+
+// GrantsFromPolicy extracts the bucket grants from the S3 resources of the
+// Allow statements in a policy. Object resources determine the granted prefixes;
+// bucket resources only add grants through an s3:prefix condition, or for
+// buckets that have no object resources at all.
+func GrantsFromPolicy(doc RolePolicyDocument) []BucketGrant {
+	var entries []string
+	var bucketOnly []string
+	hasObjects := make(map[string]bool)
+
+	for _, statement := range doc.Statement {
+		if statement.Effect != "Allow" {
+			continue
+		}
+
+		var conditionPrefixes StringList
+		if statement.Condition != nil {
+			conditionPrefixes = statement.Condition.StringLike["s3:prefix"]
+			if len(conditionPrefixes) == 0 {
+				conditionPrefixes = statement.Condition.StringEquals["s3:prefix"]
+			}
+		}
+
+		for _, resource := range statement.Resource {
+			bucket, key, ok := parseS3ARN(resource)
+			if !ok {
+				continue
+			}
+			switch {
+			case key != "":
+				hasObjects[bucket] = true
+				entries = append(entries, bucket+"/"+strings.TrimSuffix(key, "*"))
+			case len(conditionPrefixes) > 0:
+				for _, prefix := range conditionPrefixes {
+					entries = append(entries, bucket+"/"+strings.TrimSuffix(prefix, "*"))
+				}
+			default:
+				bucketOnly = append(bucketOnly, bucket)
+			}
+		}
+	}
+
+	for _, bucket := range bucketOnly {
+		if !hasObjects[bucket] {
+			entries = append(entries, bucket)
+		}
+	}
+
+	// Skip entries that cannot be expressed as a grant, such as keys with inner wildcards
+	valid := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if _, err := ParseBucketGrant(entry); err == nil {
+			valid = append(valid, entry)
+		}
+	}
+	grants, _ := ParseBucketGrants(valid)
+	return grants
+}
+
+// parseS3ARN splits an S3 resource ARN into bucket and key pattern
+func parseS3ARN(arn string) (bucket, key string, ok bool) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "s3" {
+		return "", "", false
+	}
+	bucket, key, _ = strings.Cut(parts[5], "/")
+	if bucket == "" {
+		return "", "", false
+	}
+	return bucket, key, true
+}
@@ -6,6 +6,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"path"
+	"sort"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
@@ -13,6 +16,94 @@ import (
 	"github.com/rs/zerolog"
 )
 
+const (
+	// ManagedByTagKey and ManagedByTagValue mark roles created by this tool.
+	ManagedByTagKey   = "managed-by"
+	ManagedByTagValue = "cribl-storage-tool"
+
+	// S3PolicyName is the inline policy holding the bucket grants.
+	S3PolicyName = "CrossAccountAccessPolicy"
+
+	roleDescription = "Role for cross-account access to S3"
+)
+
+// Supported Cribl actions for a cross-account role
+const (
+	ActionSearch  = "search"
+	ActionSend    = "send"
+	ActionCollect = "collect"
+	ActionReplay  = "replay"
+)
+
+// s3Permissions lists the S3 actions granted for a Cribl action, split by
+// whether they apply to the bucket itself or to the objects in it, and the KMS
+// actions needed when the bucket is encrypted with SSE-KMS.
+type s3Permissions struct {
+	Bucket []string
+	Object []string
+	KMS    []string
+}
+
+// actionPermissions maps each Cribl action to the least-privilege S3 actions it needs.
+// Search, collect and replay only read; send only writes, including multipart uploads.
+var actionPermissions = map[string]s3Permissions{
+	ActionSearch: {
+		Bucket: []string{"s3:ListBucket", "s3:GetBucketLocation"},
+		Object: []string{"s3:GetObject"},
+		KMS:    []string{"kms:Decrypt"},
+	},
+	ActionSend: {
+		Bucket: []string{"s3:GetBucketLocation", "s3:ListBucketMultipartUploads"},
+		Object: []string{"s3:PutObject", "s3:AbortMultipartUpload", "s3:ListMultipartUploadParts"},
+		KMS:    []string{"kms:GenerateDataKey", "kms:Decrypt"},
+	},
+	ActionCollect: {
+		Bucket: []string{"s3:ListBucket", "s3:GetBucketLocation"},
+		Object: []string{"s3:GetObject"},
+		KMS:    []string{"kms:Decrypt"},
+	},
+	ActionReplay: {
+		Bucket: []string{"s3:ListBucket", "s3:GetBucketLocation"},
+		Object: []string{"s3:GetObject"},
+		KMS:    []string{"kms:Decrypt"},
+	},
+}
+
+// Modes for applying the bucket list to an existing S3 policy
+const (
+	ModeReplace = "replace"
+	ModeAdd     = "add"
+	ModeRemove  = "remove"
+)
+
+// ValidateMode returns an error if mode is not a supported bucket update mode
+func ValidateMode(mode string) error {
+	switch mode {
+	case ModeReplace, ModeAdd, ModeRemove:
+		return nil
+	default:
+		return fmt.Errorf("invalid mode '%s', expected one of: %s, %s, %s", mode, ModeAdd, ModeRemove, ModeReplace)
+	}
+}
+
+// ValidActions returns the supported Cribl actions in sorted order
+func ValidActions() []string {
+	actions := make([]string, 0, len(actionPermissions))
+	for action := range actionPermissions {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	return actions
+}
+
+// ValidateAction returns an error if action is not a supported Cribl action
+func ValidateAction(action string) error {
+	if _, ok := actionPermissions[action]; !ok {
+		return fmt.Errorf("invalid action '%s', expected one of: %s", action, strings.Join(ValidActions(), ", "))
+	}
+	return nil
+}
+
 type IAMClient struct {
 	Client *iam.Client
 	logger zerolog.Logger
@@ -24,10 +115,10 @@ type TrustPolicyDocument struct {
 }
 
 type TrustStatement struct {
-	Effect    string    `json:"Effect"`
-	Principal Principal `json:"Principal"`
-	Action    []string  `json:"Action"`
-	Condition Condition `json:"Condition,omitempty"`
+	Effect    string     `json:"Effect"`
+	Principal Principal  `json:"Principal"`
+	Action    StringList `json:"Action"`
+	Condition *Condition `json:"Condition,omitempty"`
 }
 
 type RolePolicyDocument struct {
@@ -36,17 +127,62 @@ type RolePolicyDocument struct {
 }
 
 type RoleStatement struct {
-	Effect   string   `json:"Effect"`
-	Action   []string `json:"Action"`
-	Resource []string `json:"Resource"`
+	Sid       string     `json:"Sid,omitempty"`
+	Effect    string     `json:"Effect"`
+	Action    StringList `json:"Action"`
+	Resource  StringList `json:"Resource"`
+	Condition *Condition `json:"Condition,omitempty"`
 }
 
 type Principal struct {
-	AWS string `json:"AWS"`
+	AWS     StringList `json:"AWS,omitempty"`
+	Service StringList `json:"Service,omitempty"`
+}
+
+// UnmarshalJSON also accepts the "*" principal used by some existing policies
+func (p *Principal) UnmarshalJSON(data []byte) error {
+	var wildcard string
+	if err := json.Unmarshal(data, &wildcard); err == nil {
+		*p = Principal{AWS: StringList{wildcard}}
+		return nil
+	}
+	type principal Principal
+	var decoded principal
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*p = Principal(decoded)
+	return nil
 }
 
 type Condition struct {
-	StringEquals map[string]string `json:"StringEquals,omitempty"`
+	StringEquals map[string]StringList `json:"StringEquals,omitempty"`
+	StringLike   map[string]StringList `json:"StringLike,omitempty"`
+}
+
+// StringList is a policy value that IAM accepts either as a single string or a
+// list of strings. A single value is marshalled as a plain string.
+type StringList []string
+
+func (l StringList) MarshalJSON() ([]byte, error) {
+	if len(l) == 1 {
+		return json.Marshal(l[0])
+	}
+	return json.Marshal([]string(l))
+}
+
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = StringList{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*l = StringList(list)
+	return nil
 }
 
 func NewIAMClient(cfg aws.Config, logger zerolog.Logger) *IAMClient {
@@ -61,15 +197,112 @@ func NewIAMClient(cfg aws.Config, logger zerolog.Logger) *IAMClient {
 	}
 }
 
-func (c *IAMClient) SetupTrustRelationship(roleName, trustedAccountID, externalID string, workspace string,
-	workergroup string, action string, bucketNames []string) error {
+// Partition returns the partition of the client's region, which is the
+// partition roles and policies are created in
+func (c *IAMClient) Partition() string {
+	return PartitionForRegion(c.Client.Options().Region)
+}
+
+// TrustedPrincipal is a Cribl workspace and worker group allowed to assume the
+// role. SearchExec trusts the workspace's search-exec role regardless of action.
+// Partition and Path default to the commercial partition and the root path.
+type TrustedPrincipal struct {
+	Partition   string
+	AccountID   string
+	Path        string
+	Workspace   string
+	Workergroup string
+	SearchExec  bool
+}
+
+// RoleName returns the name of the Cribl role that assumes the cross-account
+// role for action. Search runs as the workspace's search-exec role, everything
+// else as the worker group role.
+func (p TrustedPrincipal) RoleName(action string) string {
+	if p.SearchExec || action == ActionSearch {
+		return searchExecRolePrefix + p.Workspace
+	}
+	return p.Workspace + "-" + p.Workergroup
+}
+
+// ARN returns the Cribl role that assumes the cross-account role for action
+func (p TrustedPrincipal) ARN(action string) string {
+	partition := p.Partition
+	if partition == "" {
+		partition = PartitionAWS
+	}
+	path := p.Path
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Sprintf("arn:%s:iam::%s:role%s%s", partition, p.AccountID, path, p.RoleName(action))
+}
+
+// PrincipalARNs returns the ARNs of principals for action without duplicates
+func PrincipalARNs(principals []TrustedPrincipal, action string) []string {
+	var arns []string
+	for _, principal := range principals {
+		arns = appendUniqueStrings(arns, principal.ARN(action))
+	}
+	return arns
+}
+
+// principalARNs returns the ARNs of principals for action, placing principals
+// without a partition in the client's partition
+func (c *IAMClient) principalARNs(principals []TrustedPrincipal, action string) []string {
+	partition := c.Partition()
+	resolved := make([]TrustedPrincipal, 0, len(principals))
+	for _, principal := range principals {
+		if principal.Partition == "" {
+			principal.Partition = partition
+		}
+		resolved = append(resolved, principal)
+	}
+	return PrincipalARNs(resolved, action)
+}
+
+// SetupOptions describes the desired state of a cross-account role.
+type SetupOptions struct {
+	RoleName    string
+	Principals  []TrustedPrincipal
+	ExternalID  string
+	Action      string
+	BucketNames []string
+	KMSKeyARNs  []string
+	// Tags are added to the role and its managed policies next to the
+	// automatic ownership tags.
+	Tags map[string]string
+	// ToolVersion is recorded in the cribl:tool-version tag when set.
+	ToolVersion string
+	// Path, Description, MaxSessionDuration and PermissionsBoundary are
+	// applied to new roles and reconciled on existing ones when set.
+	Path                string
+	Description         string
+	MaxSessionDuration  int32
+	PermissionsBoundary string
+	// AllowEmptyExternalID permits an empty ExternalID, in which case the trust
+	// policy has no sts:ExternalId condition.
+	AllowEmptyExternalID bool
+	// MergePrincipals keeps the principals already trusted by the role in
+	// addition to Principals.
+	MergePrincipals bool
+	// Mode controls how BucketNames are applied to the existing S3 policy and
+	// defaults to replace.
+	Mode string
+	// CompactWildcards allows collapsing buckets with a common naming pattern
+	// into wildcard grants when the policy is too large.
+	CompactWildcards bool
+}
+
+func (c *IAMClient) SetupTrustRelationship(opts SetupOptions) error {
 	logger := c.logger.With().
-		Str("role_name", roleName).
-		Str("trusted_account_id", trustedAccountID).
-		Str("workspace", workspace).
-		Str("workergroup", workergroup).
-		Str("action", action).
-		Strs("bucket_names", bucketNames).
+		Str("role_name", opts.RoleName).
+		Strs("principals", c.principalARNs(opts.Principals, opts.Action)).
+		Str("action", opts.Action).
+		Strs("bucket_names", opts.BucketNames).
+		Strs("kms_key_arns", opts.KMSKeyARNs).
+		Str("mode", opts.Mode).
+		Bool("merge_principals", opts.MergePrincipals).
 		Logger()
 
 	// Log the AWS region being used by the IAM client
@@ -77,90 +310,302 @@ func (c *IAMClient) SetupTrustRelationship(roleName, trustedAccountID, externalI
 		Str("aws_region", c.Client.Options().Region).
 		Msg("setting up trust relationship with AWS region")
 
-	if err := c.validateInputs(roleName, trustedAccountID, workspace, workergroup, bucketNames); err != nil {
+	if err := c.validateInputs(opts); err != nil {
 		logger.Error().Err(err).Msg("input validation failed")
 		return err
 	}
 
-	trustPolicy := c.createTrustPolicy(trustedAccountID, workspace, workergroup, externalID, action)
+	grants, kmsKeyARNs, err := c.resolveGrants(opts)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to resolve bucket grants")
+		return err
+	}
+
+	principalARNs, err := c.resolvePrincipals(opts)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to resolve trusted principals")
+		return err
+	}
+
+	trustPolicy := c.createTrustPolicy(principalARNs, opts.ExternalID)
 	logger.Debug().RawJSON("trust_policy", []byte(trustPolicy)).Msg("created trust policy")
 
-	if err := c.ensureRoleExists(roleName, trustPolicy); err != nil {
-		logger.Error().Err(err).Msg("failed to ensure role exists")
+	// Record the current state so a failure below does not leave the role half updated
+	snapshot, err := c.snapshotRole(opts.RoleName)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to snapshot role")
 		return err
 	}
 
-	if err := c.attachS3Policies(roleName, bucketNames); err != nil {
+	tags := setupTags(opts)
+	created, err := c.ensureRoleExists(opts, trustPolicy, tags)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to ensure role exists")
+		return c.rollbackSetup(snapshot, created, err)
+	}
+
+	if err := c.attachS3Policies(opts.RoleName, opts.Action, grants, kmsKeyARNs, opts.CompactWildcards, tags); err != nil {
 		logger.Error().Err(err).Msg("failed to attach S3 policies")
-		return err
+		return c.rollbackSetup(snapshot, created, err)
 	}
 
 	logger.Info().Msg("successfully set up trust relationship")
 	return nil
 }
 
-func (c *IAMClient) validateInputs(roleName, trustedAccountID, workspace, workergroup string, bucketNames []string) error {
+func (c *IAMClient) validateInputs(opts SetupOptions) error {
 	logger := c.logger.With().
-		Str("role_name", roleName).
-		Str("trusted_account_id", trustedAccountID).
-		Str("workspace", workspace).
-		Str("workergroup", workergroup).
-		Strs("bucket_names", bucketNames).
+		Str("role_name", opts.RoleName).
+		Int("principal_count", len(opts.Principals)).
+		Str("action", opts.Action).
+		Strs("bucket_names", opts.BucketNames).
 		Logger()
 
 	logger.Debug().Msg("validating inputs")
 
-	if roleName == "" {
+	if opts.RoleName == "" {
 		logger.Error().Msg("role name is empty")
 		return fmt.Errorf("roleName cannot be empty")
 	}
-	if trustedAccountID == "" {
-		logger.Error().Msg("trusted account ID is empty")
-		return fmt.Errorf("trustedAccountID cannot be empty")
+	if err := ValidateRoleName(opts.RoleName); err != nil {
+		logger.Error().Err(err).Msg("invalid role name")
+		return err
+	}
+	if len(opts.Principals) == 0 {
+		logger.Error().Msg("no trusted principals provided")
+		return fmt.Errorf("at least one trusted principal must be provided")
+	}
+	partition := c.Partition()
+	for _, principal := range opts.Principals {
+		if principal.AccountID == "" {
+			logger.Error().Msg("trusted account ID is empty")
+			return fmt.Errorf("trustedAccountID cannot be empty")
+		}
+		if err := ValidateAccountID(principal.AccountID); err != nil {
+			logger.Error().Err(err).Msg("invalid trusted account ID")
+			return err
+		}
+		if principal.Workspace == "" {
+			logger.Error().Msg("workspace is empty")
+			return fmt.Errorf("workspace cannot be empty")
+		}
+		if principal.Partition != "" && principal.Partition != partition {
+			logger.Error().Str("partition", principal.Partition).Msg("principal is in another partition")
+			return fmt.Errorf("principal %s is in partition '%s' but the role is created in '%s'; roles cannot trust principals in another partition",
+				principal.ARN(opts.Action), principal.Partition, partition)
+		}
+		if err := ValidateRoleName(principal.RoleName(opts.Action)); err != nil {
+			logger.Error().Err(err).Msg("invalid Cribl role name")
+			return fmt.Errorf("invalid workspace '%s' or worker group '%s': %w", principal.Workspace, principal.Workergroup, err)
+		}
+	}
+	if opts.ExternalID == "" && !opts.AllowEmptyExternalID {
+		logger.Error().Msg("external ID is empty")
+		return fmt.Errorf("externalID cannot be empty")
 	}
-	if len(bucketNames) == 0 {
+	if err := ValidateAction(opts.Action); err != nil {
+		logger.Error().Msg("invalid action")
+		return err
+	}
+	if len(opts.BucketNames) == 0 {
 		logger.Error().Msg("no bucket names provided")
 		return fmt.Errorf("at least one bucketName must be provided")
 	}
+	grants, err := ParseBucketGrants(opts.BucketNames)
+	if err != nil {
+		logger.Error().Err(err).Msg("invalid bucket entry")
+		return err
+	}
+	for _, grant := range grants {
+		if err := ValidateBucketName(grant.Bucket); err != nil {
+			logger.Error().Err(err).Msg("invalid bucket name")
+			return err
+		}
+	}
+	for _, keyARN := range opts.KMSKeyARNs {
+		if _, err := ParseKMSKeyARN(keyARN); err != nil {
+			logger.Error().Err(err).Msg("invalid KMS key ARN")
+			return err
+		}
+	}
+	if err := validateRoleSettings(opts); err != nil {
+		logger.Error().Err(err).Msg("invalid role settings")
+		return err
+	}
+	if err := validateTags(opts.Tags); err != nil {
+		logger.Error().Err(err).Msg("invalid tag")
+		return err
+	}
+	if opts.Mode != "" {
+		if err := ValidateMode(opts.Mode); err != nil {
+			logger.Error().Msg("invalid mode")
+			return err
+		}
+	}
 
 	logger.Debug().Msg("input validation successful")
 	return nil
 }
 
-func (c *IAMClient) createTrustPolicy(trustedAccountID, workspace, workergroup, externalID, action string) string {
+// resolveGrants applies opts.BucketNames to the role's current S3 policy
+// according to opts.Mode. In add and remove mode the KMS keys already granted
+// are kept alongside opts.KMSKeyARNs.
+func (c *IAMClient) resolveGrants(opts SetupOptions) ([]BucketGrant, []string, error) {
 	logger := c.logger.With().
-		Str("trusted_account_id", trustedAccountID).
-		Str("workspace", workspace).
-		Str("workergroup", workergroup).
-		Str("action", action).
+		Str("role_name", opts.RoleName).
+		Str("mode", opts.Mode).
 		Logger()
 
-	logger.Debug().Msg("creating trust policy")
+	requested, err := ParseBucketGrants(opts.BucketNames)
+	if err != nil {
+		return nil, nil, err
+	}
+	if opts.Mode == "" || opts.Mode == ModeReplace {
+		return requested, opts.KMSKeyARNs, nil
+	}
 
-	policy := TrustPolicyDocument{
-		Version: "2012-10-17",
-		Statement: []TrustStatement{
-			{
-				Effect: "Allow",
-				Action: []string{"sts:AssumeRole", "sts:TagSession", "sts:SetSourceIdentity"},
-				Condition: Condition{
-					StringEquals: map[string]string{
-						"sts:ExternalId": externalID,
-					},
-				},
-			},
-		},
+	current, err := c.getS3Policy(opts.RoleName)
+	if err != nil {
+		return nil, nil, err
+	}
+	existing := GrantsFromPolicy(current)
+	kmsKeyARNs := appendUniqueStrings(kmsKeysFromPolicy(current), opts.KMSKeyARNs...)
+
+	var entries []string
+	switch opts.Mode {
+	case ModeAdd:
+		entries = append(grantStrings(existing), grantStrings(requested)...)
+	case ModeRemove:
+		for _, grant := range existing {
+			if grantRemoved(grant, requested) {
+				logger.Info().Str("bucket", grant.String()).Msg("removing bucket grant")
+				continue
+			}
+			entries = append(entries, grant.String())
+		}
+		for _, grant := range requested {
+			// A bucket granted through a wildcard cannot be removed on its own
+			if pattern, ok := coveringWildcard(existing, grant); ok {
+				return nil, nil, fmt.Errorf("cannot remove bucket '%s' from role '%s': it is granted by the wildcard '%s'; use --mode replace with the buckets to keep instead",
+					grant.String(), opts.RoleName, pattern)
+			}
+			if grant.Prefix != "" && containsGrant(existing, BucketGrant{Bucket: grant.Bucket}) {
+				logger.Warn().Str("bucket", grant.String()).Msg("cannot remove a prefix from a whole-bucket grant")
+			}
+		}
 	}
 
-	if action != "search" {
-		policy.Statement[0].Principal = Principal{
-			AWS: fmt.Sprintf("arn:aws:iam::%s:role/%s-%s", trustedAccountID, workspace, workergroup),
+	grants, err := ParseBucketGrants(entries)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(grants) == 0 {
+		return nil, nil, fmt.Errorf("removing the requested buckets would leave role '%s' without any buckets, use iam teardown instead", opts.RoleName)
+	}
+
+	logger.Info().
+		Int("existing_grants", len(existing)).
+		Int("resulting_grants", len(grants)).
+		Msg("resolved bucket grants")
+	return grants, kmsKeyARNs, nil
+}
+
+// grantRemoved reports whether grant is covered by one of the grants to remove.
+// Removing a whole bucket also removes its prefix grants.
+func grantRemoved(grant BucketGrant, remove []BucketGrant) bool {
+	for _, r := range remove {
+		if r.Bucket == grant.Bucket && (r.Prefix == "" || r.Prefix == grant.Prefix) {
+			return true
 		}
-	} else {
-		policy.Statement[0].Principal = Principal{
-			AWS: fmt.Sprintf("arn:aws:iam::%s:role/search-exec-%s", trustedAccountID, workspace),
+	}
+	return false
+}
+
+// coveringWildcard returns the wildcard grant that covers grant when no grant
+// would be removed for it by name
+func coveringWildcard(grants []BucketGrant, grant BucketGrant) (string, bool) {
+	for _, g := range grants {
+		if grantRemoved(g, []BucketGrant{grant}) {
+			return "", false
 		}
 	}
+	for _, g := range grants {
+		if !strings.ContainsAny(g.Bucket, "*?") {
+			continue
+		}
+		matched, err := path.Match(g.Bucket, grant.Bucket)
+		if err == nil && matched && (g.Prefix == "" || strings.HasPrefix(grant.Prefix, g.Prefix)) {
+			return g.String(), true
+		}
+	}
+	return "", false
+}
+
+func containsGrant(grants []BucketGrant, grant BucketGrant) bool {
+	for _, g := range grants {
+		if g == grant {
+			return true
+		}
+	}
+	return false
+}
+
+// CriblPrincipalARN returns the Cribl role in the commercial partition that
+// assumes the cross-account role for action
+func CriblPrincipalARN(trustedAccountID, workspace, workergroup, action string) string {
+	return TrustedPrincipal{AccountID: trustedAccountID, Workspace: workspace, Workergroup: workergroup}.ARN(action)
+}
+
+// resolvePrincipals returns the principal ARNs to trust. With
+// opts.MergePrincipals the AWS principals already trusted by the role are kept.
+func (c *IAMClient) resolvePrincipals(opts SetupOptions) ([]string, error) {
+	principalARNs := c.principalARNs(opts.Principals, opts.Action)
+	if !opts.MergePrincipals {
+		return principalARNs, nil
+	}
+
+	currentTrust, err := c.getTrustPolicy(opts.RoleName)
+	if err != nil {
+		return nil, err
+	}
+	if currentTrust == "" {
+		return principalARNs, nil
+	}
+	var current TrustPolicyDocument
+	if err := json.Unmarshal([]byte(currentTrust), &current); err != nil {
+		return nil, fmt.Errorf("failed to parse trust policy of role '%s': %w", opts.RoleName, err)
+	}
+	return appendUniqueStrings(principalARNs, trustedPrincipals(current)...), nil
+}
+
+// createTrustPolicy builds a trust policy allowing every principal ARN to assume
+// the role with the external ID. An empty externalID omits the condition.
+func (c *IAMClient) createTrustPolicy(principalARNs []string, externalID string) string {
+	logger := c.logger.With().
+		Strs("principals", principalARNs).
+		Logger()
+
+	logger.Debug().Msg("creating trust policy")
+
+	statement := TrustStatement{
+		Effect: "Allow",
+		Principal: Principal{
+			AWS: StringList(principalARNs),
+		},
+		Action: []string{"sts:AssumeRole", "sts:TagSession", "sts:SetSourceIdentity"},
+	}
+	if externalID != "" {
+		statement.Condition = &Condition{
+			StringEquals: map[string]StringList{
+				"sts:ExternalId": {externalID},
+			},
+		}
+	}
+
+	policy := TrustPolicyDocument{
+		Version:   "2012-10-17",
+		Statement: []TrustStatement{statement},
+	}
 
 	policyJSON, err := json.Marshal(policy)
 	if err != nil {
@@ -172,10 +617,13 @@ func (c *IAMClient) createTrustPolicy(trustedAccountID, workspace, workergroup,
 	return string(policyJSON)
 }
 
-func (c *IAMClient) ensureRoleExists(roleName, trustPolicy string) error {
+// ensureRoleExists creates the role, or updates the settings, trust policy and
+// tags of an existing one. It reports whether this call created the role.
+func (c *IAMClient) ensureRoleExists(opts SetupOptions, trustPolicy string, tags map[string]string) (bool, error) {
+	roleName := opts.RoleName
 	logger := c.logger.With().Str("role_name", roleName).Logger()
 
-	_, err := c.Client.GetRole(context.TODO(), &iam.GetRoleInput{
+	out, err := c.Client.GetRole(context.TODO(), &iam.GetRoleInput{
 		RoleName: aws.String(roleName),
 	})
 
@@ -183,24 +631,55 @@ func (c *IAMClient) ensureRoleExists(roleName, trustPolicy string) error {
 		var noSuchEntity *types.NoSuchEntityException
 		if errors.As(err, &noSuchEntity) {
 			logger.Info().Msg("role does not exist, creating new role")
-			return c.createRole(roleName, trustPolicy)
+			if err := c.createRole(roleName, trustPolicy, desiredRoleSettings(opts, nil), tags); err != nil {
+				return false, err
+			}
+			return true, nil
 		}
 		logger.Error().Err(err).Msg("failed to get IAM role")
-		return fmt.Errorf("failed to get IAM role: %w", err)
+		return false, fmt.Errorf("failed to get IAM role: %w", err)
+	}
+
+	// Check the settings first, a role under the wrong path is not updated at all
+	if err := c.reconcileRoleSettings(out.Role, opts); err != nil {
+		return false, err
 	}
 
 	logger.Info().Msg("updating existing role trust policy")
-	return c.updateRoleTrustPolicy(roleName, trustPolicy)
+	if err := c.updateRoleTrustPolicy(roleName, trustPolicy); err != nil {
+		return false, err
+	}
+	return false, c.reconcileRoleTags(roleName, tags)
+}
+
+// GetRoleARN returns the ARN of an existing role
+func (c *IAMClient) GetRoleARN(roleName string) (string, error) {
+	out, err := c.Client.GetRole(context.TODO(), &iam.GetRoleInput{
+		RoleName: aws.String(roleName),
+	})
+	if err != nil {
+		c.logger.Error().Err(err).Str("role_name", roleName).Msg("failed to get IAM role")
+		return "", fmt.Errorf("failed to get IAM role: %w", err)
+	}
+	return aws.ToString(out.Role.Arn), nil
 }
 
-func (c *IAMClient) createRole(roleName, trustPolicy string) error {
+func (c *IAMClient) createRole(roleName, trustPolicy string, settings RoleSettings, tags map[string]string) error {
 	logger := c.logger.With().Str("role_name", roleName).Logger()
 
-	_, err := c.Client.CreateRole(context.TODO(), &iam.CreateRoleInput{
+	input := &iam.CreateRoleInput{
 		RoleName:                 aws.String(roleName),
 		AssumeRolePolicyDocument: aws.String(trustPolicy),
-		Description:              aws.String("Role for cross-account access to S3"),
-	})
+		Path:                     aws.String(settings.Path),
+		Description:              aws.String(settings.Description),
+		MaxSessionDuration:       aws.Int32(settings.MaxSessionDuration),
+		Tags:                     iamTags(tags),
+	}
+	if settings.PermissionsBoundary != "" {
+		input.PermissionsBoundary = aws.String(settings.PermissionsBoundary)
+	}
+
+	_, err := c.Client.CreateRole(context.TODO(), input)
 	if err != nil {
 		logger.Error().Err(err).Msg("failed to create IAM role")
 		return fmt.Errorf("failed to create IAM role: %w", err)
@@ -226,56 +705,118 @@ func (c *IAMClient) updateRoleTrustPolicy(roleName, trustPolicy string) error {
 	return nil
 }
 
-func (c *IAMClient) attachS3Policies(roleName string, bucketNames []string) error {
+func (c *IAMClient) attachS3Policies(roleName, action string, grants []BucketGrant, kmsKeyARNs []string, compact bool, tags map[string]string) error {
 	logger := c.logger.With().
 		Str("role_name", roleName).
-		Strs("bucket_names", bucketNames).
+		Str("action", action).
+		Int("grant_count", len(grants)).
 		Logger()
 
-	policyName := "CrossAccountAccessPolicy"
-	policyDocument := c.createS3PolicyDocument(bucketNames)
-
-	logger.Debug().RawJSON("policy_document", []byte(policyDocument)).Msg("creating S3 policy")
-
-	_, err := c.Client.PutRolePolicy(context.TODO(), &iam.PutRolePolicyInput{
-		RoleName:       aws.String(roleName),
-		PolicyName:     aws.String(policyName),
-		PolicyDocument: aws.String(policyDocument),
-	})
+	// Check the managed policy quota before any policy is created
+	budget, err := c.availablePolicyBudget(roleName)
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to attach policy to role")
-		return fmt.Errorf("failed to attach policy to role '%s': %w", roleName, err)
+		return err
+	}
+	specs, err := c.buildS3Policies(roleName, action, grants, kmsKeyARNs, compact, budget)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to build S3 policies")
+		return err
 	}
 
-	logger.Info().Str("policy_name", policyName).Msg("attached policy to IAM role")
+	if err := c.putS3Policies(roleName, specs, tags); err != nil {
+		return err
+	}
+
+	logger.Info().Int("policy_count", len(specs)).Msg("attached S3 policies to IAM role")
 	return nil
 }
 
-func (c *IAMClient) createS3PolicyDocument(bucketNames []string) string {
-	logger := c.logger.With().Strs("bucket_names", bucketNames).Logger()
+// createS3PolicyDocument builds the least-privilege S3 policy for action, with
+// bucket-level and object-level actions in separate statements. Prefix grants
+// get object ARNs limited to the prefix and s3:ListBucket statements with an
+// s3:prefix condition. KMS keys get a statement limited to use through S3.
+func (c *IAMClient) createS3PolicyDocument(action string, grants []BucketGrant, kmsKeyARNs []string) string {
+	logger := c.logger.With().
+		Str("action", action).
+		Int("grant_count", len(grants)).
+		Logger()
 	logger.Debug().Msg("creating S3 policy document")
 
-	resources := make([]string, 0, len(bucketNames)*2)
-	for _, bucket := range bucketNames {
-		resources = append(resources,
-			fmt.Sprintf("arn:aws:s3:::%s", bucket),
-			fmt.Sprintf("arn:aws:s3:::%s/*", bucket))
+	permissions := actionPermissions[action]
+	partition := c.Partition()
+
+	var listBucket bool
+	var prefixBucketActions []string
+	for _, a := range permissions.Bucket {
+		if a == "s3:ListBucket" {
+			listBucket = true
+		} else {
+			prefixBucketActions = append(prefixBucketActions, a)
+		}
 	}
 
-	policy := RolePolicyDocument{
-		Version: "2012-10-17",
-		Statement: []RoleStatement{
-			{
-				Effect: "Allow",
-				Action: []string{
-					"s3:ListBucket",
-					"s3:GetObject",
-					"s3:PutObject",
-					"s3:GetBucketLocation",
+	var wholeBucketResources, prefixBucketResources, objectResources []string
+	var prefixBuckets []string
+	prefixes := make(map[string][]string)
+	for _, grant := range grants {
+		objectResources = append(objectResources, grant.ObjectARN(partition))
+		if grant.Prefix == "" {
+			wholeBucketResources = append(wholeBucketResources, grant.BucketARN(partition))
+			continue
+		}
+		if _, ok := prefixes[grant.Bucket]; !ok {
+			prefixBuckets = append(prefixBuckets, grant.Bucket)
+			prefixBucketResources = append(prefixBucketResources, grant.BucketARN(partition))
+		}
+		prefixes[grant.Bucket] = append(prefixes[grant.Bucket], grant.Prefix, grant.Prefix+"*")
+	}
+
+	var statements []RoleStatement
+	if len(wholeBucketResources) > 0 {
+		statements = append(statements, RoleStatement{
+			Sid:      "CriblBucketAccess",
+			Effect:   "Allow",
+			Action:   permissions.Bucket,
+			Resource: wholeBucketResources,
+		})
+	}
+	if len(prefixBucketResources) > 0 && len(prefixBucketActions) > 0 {
+		statements = append(statements, RoleStatement{
+			Sid:      "CriblPrefixBucketAccess",
+			Effect:   "Allow",
+			Action:   prefixBucketActions,
+			Resource: prefixBucketResources,
+		})
+	}
+	if listBucket {
+		for i, bucket := range prefixBuckets {
+			grant := BucketGrant{Bucket: bucket}
+			statements = append(statements, RoleStatement{
+				Sid:      fmt.Sprintf("CriblListPrefix%d", i+1),
+				Effect:   "Allow",
+				Action:   []string{"s3:ListBucket"},
+				Resource: []string{grant.BucketARN(partition)},
+				Condition: &Condition{
+					StringLike: map[string]StringList{
+						"s3:prefix": prefixes[bucket],
+					},
 				},
-				Resource: resources,
-			},
-		},
+			})
+		}
+	}
+	statements = append(statements, RoleStatement{
+		Sid:      "CriblObjectAccess",
+		Effect:   "Allow",
+		Action:   permissions.Object,
+		Resource: objectResources,
+	})
+	if len(kmsKeyARNs) > 0 {
+		statements = append(statements, kmsStatement(permissions.KMS, kmsKeyARNs))
+	}
+
+	policy := RolePolicyDocument{
+		Version:   "2012-10-17",
+		Statement: statements,
 	}
 
 	policyJSON, err := json.Marshal(policy)
@@ -287,3 +828,22 @@ func (c *IAMClient) createS3PolicyDocument(bucketNames []string) string {
 	logger.Debug().RawJSON("policy", policyJSON).Msg("S3 policy document created")
 	return string(policyJSON)
 }
+
+// appendUniqueStrings appends the values not already present in list
+func appendUniqueStrings(list []string, values ...string) []string {
+	for _, value := range values {
+		if !containsString(list, value) {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
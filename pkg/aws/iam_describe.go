@@ -0,0 +1,204 @@
+// pkg/aws/iam_describe.go
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// RoleDescription summarises a role in Cribl terms: who can assume it and what
+// it can access
+type RoleDescription struct {
+	*ImportReport
+	Settings   RoleSettings      `json:"settings"`
+	ExternalID bool              `json:"external_id_enforced"`
+	Access     []S3Access        `json:"access"`
+	Tags       map[string]string `json:"tags"`
+}
+
+// S3Access is the set of S3 actions a role is allowed on a bucket or prefix
+type S3Access struct {
+	Bucket  string   `json:"bucket"`
+	Prefix  string   `json:"prefix,omitempty"`
+	Actions []string `json:"actions"`
+}
+
+// DescribeRole fetches a role and its inline and attached managed policies
+// and decodes them into the Cribl principals that can assume it and the
+// buckets, prefixes and actions it grants
+func (c *IAMClient) DescribeRole(roleName string) (*RoleDescription, error) {
+	if roleName == "" {
+		return nil, fmt.Errorf("roleName cannot be empty")
+	}
+
+	role, err := c.getRole(roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, fmt.Errorf("role '%s' does not exist", roleName)
+	}
+
+	report, policy, err := c.inspectRole(role)
+	if err != nil {
+		return nil, err
+	}
+
+	description := &RoleDescription{
+		ImportReport: report,
+		Settings:     currentRoleSettings(role),
+		ExternalID:   len(report.ExternalIDs) > 0,
+		Access:       s3AccessFromPolicy(policy),
+		Tags:         make(map[string]string, len(role.Tags)),
+	}
+	for _, tag := range role.Tags {
+		description.Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+
+	c.logger.Debug().Str("role_name", roleName).Int("access_count", len(description.Access)).Msg("described role")
+	return description, nil
+}
+
+// s3AccessFromPolicy groups the S3 actions of the Allow statements in a policy
+// by bucket and prefix. Bucket-level statements limited by an s3:prefix
+// condition are reported against that prefix.
+func s3AccessFromPolicy(doc RolePolicyDocument) []S3Access {
+	var access []S3Access
+	index := make(map[string]int)
+	add := func(bucket, prefix string, actions []string) {
+		key := bucket + "/" + prefix
+		i, ok := index[key]
+		if !ok {
+			i = len(access)
+			index[key] = i
+			access = append(access, S3Access{Bucket: bucket, Prefix: prefix})
+		}
+		access[i].Actions = appendUniqueStrings(access[i].Actions, actions...)
+	}
+
+	for _, statement := range doc.Statement {
+		if statement.Effect != "Allow" {
+			continue
+		}
+		var s3Actions []string
+		for _, action := range statement.Action {
+			if strings.HasPrefix(strings.ToLower(action), "s3:") || action == "*" {
+				s3Actions = append(s3Actions, action)
+			}
+		}
+		if len(s3Actions) == 0 {
+			continue
+		}
+
+		var conditionPrefixes StringList
+		if statement.Condition != nil {
+			conditionPrefixes = statement.Condition.StringLike["s3:prefix"]
+			if len(conditionPrefixes) == 0 {
+				conditionPrefixes = statement.Condition.StringEquals["s3:prefix"]
+			}
+		}
+
+		for _, resource := range statement.Resource {
+			bucket, key, ok := parseS3ARN(resource)
+			if !ok {
+				continue
+			}
+			switch {
+			case key != "":
+				add(bucket, strings.TrimSuffix(key, "*"), s3Actions)
+			case len(conditionPrefixes) > 0:
+				for _, prefix := range conditionPrefixes {
+					add(bucket, strings.TrimSuffix(prefix, "*"), s3Actions)
+				}
+			default:
+				add(bucket, "", s3Actions)
+			}
+		}
+	}
+
+	for i := range access {
+		sort.Strings(access[i].Actions)
+	}
+	sort.Slice(access, func(i, j int) bool {
+		if access[i].Bucket != access[j].Bucket {
+			return access[i].Bucket < access[j].Bucket
+		}
+		return access[i].Prefix < access[j].Prefix
+	})
+	return access
+}
+
+// PrintText prints the role description in a human-readable format
+func (d *RoleDescription) PrintText() {
+	fmt.Printf("Role %q (%s)\n", d.RoleName, d.RoleARN)
+	fmt.Printf("  path: %s, max session duration: %ds\n", d.Settings.Path, d.Settings.MaxSessionDuration)
+	if d.Settings.Description != "" {
+		fmt.Printf("  description: %s\n", d.Settings.Description)
+	}
+	if d.Settings.PermissionsBoundary != "" {
+		fmt.Printf("  permissions boundary: %s\n", d.Settings.PermissionsBoundary)
+	}
+	if d.AlreadyManaged {
+		fmt.Println("  managed by cribl-storage-tool")
+	}
+
+	fmt.Printf("\nCribl action: %s\n", d.Action)
+
+	fmt.Println("\nWho can assume it:")
+	if len(d.Principals) == 0 {
+		fmt.Println("  nobody: the trust policy allows no AWS principals")
+	}
+	for _, principal := range d.Principals {
+		switch {
+		case !principal.Recognized:
+			fmt.Printf("  %s (not a Cribl role)\n", principal.ARN)
+		case principal.Ambiguous:
+			fmt.Printf("  %s of account %s (workspace and worker group are ambiguous)\n", principal.ARN, principal.AccountID)
+		case principal.SearchExec:
+			fmt.Printf("  Cribl Search in workspace %s of account %s\n", principal.Workspace, principal.AccountID)
+		default:
+			fmt.Printf("  workspace %s, worker group %s of account %s\n", principal.Workspace, principal.Workergroup, principal.AccountID)
+		}
+	}
+	switch len(d.ExternalIDs) {
+	case 0:
+		fmt.Println("  ! no external ID is enforced")
+	case 1:
+		fmt.Println("  external ID is enforced")
+	default:
+		fmt.Printf("  external ID is enforced, %d IDs accepted (rotation in progress)\n", len(d.ExternalIDs))
+	}
+
+	fmt.Println("\nWhat it can access:")
+	if len(d.Access) == 0 {
+		fmt.Println("  no S3 access")
+	}
+	for _, access := range d.Access {
+		target := access.Bucket
+		if access.Prefix != "" {
+			target += "/" + access.Prefix
+		}
+		fmt.Printf("  %s: %s\n", target, strings.Join(access.Actions, ", "))
+	}
+	for _, keyARN := range d.KMSKeyARNs {
+		fmt.Printf("  kms key %s\n", keyARN)
+	}
+
+	if len(d.Policies) > 0 {
+		fmt.Printf("\nPolicies: %s\n", strings.Join(d.Policies, ", "))
+	}
+}
+
+// PrintJSON prints the role description in JSON format
+func (d *RoleDescription) PrintJSON() error {
+	jsonData, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(jsonData))
+	return nil
+}
@@ -0,0 +1,192 @@
+// pkg/aws/iam_drift.go
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DriftReport compares a role as it exists today with its desired state.
+// Added entries are on the role but not desired, removed entries are desired
+// but missing from the role.
+type DriftReport struct {
+	RoleName           string   `json:"role_name"`
+	Drift              bool     `json:"drift"`
+	BucketsAdded       []string `json:"buckets_added"`
+	BucketsRemoved     []string `json:"buckets_removed"`
+	PrincipalsAdded    []string `json:"principals_added"`
+	PrincipalsRemoved  []string `json:"principals_removed"`
+	ExternalIDChecked  bool     `json:"external_id_checked"`
+	ExternalIDMismatch bool     `json:"external_id_mismatch"`
+	ExternalIDsOnRole  int      `json:"external_ids_on_role"`
+	S3PolicyMissing    bool     `json:"s3_policy_missing"`
+}
+
+// HasDrift reports whether the role differs from its desired state
+func (r *DriftReport) HasDrift() bool {
+	return len(r.BucketsAdded) > 0 || len(r.BucketsRemoved) > 0 ||
+		len(r.PrincipalsAdded) > 0 || len(r.PrincipalsRemoved) > 0 ||
+		r.ExternalIDMismatch || r.S3PolicyMissing
+}
+
+// DetectDrift reads the role's trust policy and S3 policy and compares them
+// with opts. The external ID is only compared when opts.ExternalID is set.
+func (c *IAMClient) DetectDrift(opts SetupOptions) (*DriftReport, error) {
+	logger := c.logger.With().
+		Str("role_name", opts.RoleName).
+		Str("action", opts.Action).
+		Logger()
+
+	// The external ID is optional here, it is only compared when given
+	opts.AllowEmptyExternalID = true
+	if err := c.validateInputs(opts); err != nil {
+		logger.Error().Err(err).Msg("input validation failed")
+		return nil, err
+	}
+
+	trustDocument, err := c.getTrustPolicy(opts.RoleName)
+	if err != nil {
+		return nil, err
+	}
+	if trustDocument == "" {
+		return nil, fmt.Errorf("role '%s' does not exist", opts.RoleName)
+	}
+	var trustPolicy TrustPolicyDocument
+	if err := json.Unmarshal([]byte(trustDocument), &trustPolicy); err != nil {
+		return nil, fmt.Errorf("failed to parse trust policy of role '%s': %w", opts.RoleName, err)
+	}
+
+	s3Policy, err := c.getS3Policy(opts.RoleName)
+	if err != nil {
+		return nil, err
+	}
+
+	desiredGrants, err := ParseBucketGrants(opts.BucketNames)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DriftReport{
+		RoleName:        opts.RoleName,
+		S3PolicyMissing: len(s3Policy.Statement) == 0,
+	}
+	report.BucketsAdded, report.BucketsRemoved = diffStringSets(
+		grantStrings(GrantsFromPolicy(s3Policy)), grantStrings(desiredGrants))
+
+	report.PrincipalsAdded, report.PrincipalsRemoved = diffStringSets(
+		trustedPrincipals(trustPolicy), c.principalARNs(opts.Principals, opts.Action))
+
+	externalIDs := trustExternalIDs(trustPolicy)
+	report.ExternalIDsOnRole = len(externalIDs)
+	if opts.ExternalID != "" {
+		report.ExternalIDChecked = true
+		report.ExternalIDMismatch = len(externalIDs) != 1 || externalIDs[0] != opts.ExternalID
+	}
+
+	report.Drift = report.HasDrift()
+	logger.Info().Bool("drift", report.Drift).Msg("checked role for drift")
+	return report, nil
+}
+
+// trustedPrincipals returns the AWS principals allowed to assume the role
+func trustedPrincipals(doc TrustPolicyDocument) []string {
+	var principals []string
+	for _, statement := range doc.Statement {
+		if statement.Effect != "Allow" || !containsString(statement.Action, "sts:AssumeRole") {
+			continue
+		}
+		for _, principal := range statement.Principal.AWS {
+			if !containsString(principals, principal) {
+				principals = append(principals, principal)
+			}
+		}
+	}
+	return principals
+}
+
+// trustExternalIDs returns the external IDs required by the trust policy
+func trustExternalIDs(doc TrustPolicyDocument) []string {
+	var externalIDs []string
+	for _, statement := range doc.Statement {
+		if statement.Condition == nil {
+			continue
+		}
+		for key, values := range statement.Condition.StringEquals {
+			if !strings.EqualFold(key, "sts:ExternalId") {
+				continue
+			}
+			for _, value := range values {
+				if !containsString(externalIDs, value) {
+					externalIDs = append(externalIDs, value)
+				}
+			}
+		}
+	}
+	return externalIDs
+}
+
+func grantStrings(grants []BucketGrant) []string {
+	values := make([]string, 0, len(grants))
+	for _, grant := range grants {
+		values = append(values, grant.String())
+	}
+	return values
+}
+
+// diffStringSets returns the values only in actual and the values only in desired
+func diffStringSets(actual, desired []string) (added, removed []string) {
+	added = []string{}
+	removed = []string{}
+	for _, value := range actual {
+		if !containsString(desired, value) {
+			added = append(added, value)
+		}
+	}
+	for _, value := range desired {
+		if !containsString(actual, value) {
+			removed = append(removed, value)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// PrintText prints the drift report in a human-readable format
+func (r *DriftReport) PrintText() {
+	fmt.Printf("Drift report for role %q:\n", r.RoleName)
+	if !r.HasDrift() {
+		fmt.Println("  no drift detected")
+		return
+	}
+	if r.S3PolicyMissing {
+		fmt.Printf("  ! policy %s is missing\n", S3PolicyName)
+	}
+	for _, bucket := range r.BucketsAdded {
+		fmt.Printf("  + bucket %s (on role, not desired)\n", bucket)
+	}
+	for _, bucket := range r.BucketsRemoved {
+		fmt.Printf("  - bucket %s (desired, missing from role)\n", bucket)
+	}
+	for _, principal := range r.PrincipalsAdded {
+		fmt.Printf("  + principal %s (trusted, not desired)\n", principal)
+	}
+	for _, principal := range r.PrincipalsRemoved {
+		fmt.Printf("  - principal %s (desired, not trusted)\n", principal)
+	}
+	if r.ExternalIDMismatch {
+		fmt.Printf("  ~ external ID does not match (%d external ID(s) on role)\n", r.ExternalIDsOnRole)
+	}
+}
+
+// PrintJSON prints the drift report in JSON format
+func (r *DriftReport) PrintJSON() error {
+	jsonData, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(jsonData))
+	return nil
+}
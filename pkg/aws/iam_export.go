@@ -0,0 +1,256 @@
+// pkg/aws/iam_export.go
+package aws
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Supported formats for exporting a role setup as a template
+const (
+	ExportFormatTerraform      = "terraform"
+	ExportFormatCloudFormation = "cloudformation"
+	ExportFormatJSON           = "json"
+)
+
+// ValidateExportFormat returns an error if format is not a supported export format
+func ValidateExportFormat(format string) error {
+	switch format {
+	case ExportFormatTerraform, ExportFormatCloudFormation, ExportFormatJSON:
+		return nil
+	default:
+		return fmt.Errorf("invalid output format '%s', expected one of: %s, %s, %s",
+			format, ExportFormatTerraform, ExportFormatCloudFormation, ExportFormatJSON)
+	}
+}
+
+// RoleExport is the role, trust policy and S3 policies SetupTrustRelationship
+// would create, in a form that can be rendered as a template
+type RoleExport struct {
+	RoleName            string            `json:"role_name"`
+	Path                string            `json:"path"`
+	Description         string            `json:"description"`
+	MaxSessionDuration  int32             `json:"max_session_duration"`
+	PermissionsBoundary string            `json:"permissions_boundary,omitempty"`
+	Tags                map[string]string `json:"tags"`
+	TrustPolicy         json.RawMessage   `json:"trust_policy"`
+	Policies            []PolicyExport    `json:"policies"`
+}
+
+// PolicyExport is one S3 policy of an exported role
+type PolicyExport struct {
+	Name     string          `json:"name"`
+	Managed  bool            `json:"managed"`
+	Document json.RawMessage `json:"document"`
+}
+
+// ExportSetup renders the resources SetupTrustRelationship would create for
+// opts as a template in format, without calling AWS. The add and remove modes
+// and merging principals depend on the role's current state and are rejected.
+func (c *IAMClient) ExportSetup(opts SetupOptions, format string) ([]byte, error) {
+	logger := c.logger.With().
+		Str("role_name", opts.RoleName).
+		Str("format", format).
+		Logger()
+
+	if err := ValidateExportFormat(format); err != nil {
+		return nil, err
+	}
+	if err := c.validateInputs(opts); err != nil {
+		logger.Error().Err(err).Msg("input validation failed")
+		return nil, err
+	}
+	if opts.Mode != "" && opts.Mode != ModeReplace {
+		return nil, fmt.Errorf("mode '%s' reads the existing role and cannot be exported, use %s", opts.Mode, ModeReplace)
+	}
+	if opts.MergePrincipals {
+		return nil, fmt.Errorf("merging principals reads the existing role and cannot be exported")
+	}
+
+	grants, err := ParseBucketGrants(opts.BucketNames)
+	if err != nil {
+		return nil, err
+	}
+	// The exported role is new, so it has the default policy quota to itself
+	specs, err := c.buildS3Policies(opts.RoleName, opts.Action, grants, opts.KMSKeyARNs, opts.CompactWildcards, policyBudget{
+		InlineSize:      inlinePolicySizeLimit,
+		ManagedPolicies: defaultAttachedPoliciesPerRole,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	settings := desiredRoleSettings(opts, nil)
+	export := RoleExport{
+		RoleName:            opts.RoleName,
+		Path:                settings.Path,
+		Description:         settings.Description,
+		MaxSessionDuration:  settings.MaxSessionDuration,
+		PermissionsBoundary: settings.PermissionsBoundary,
+		Tags:                setupTags(opts),
+		TrustPolicy:         json.RawMessage(c.createTrustPolicy(c.principalARNs(opts.Principals, opts.Action), opts.ExternalID)),
+	}
+	for _, spec := range specs {
+		export.Policies = append(export.Policies, PolicyExport{
+			Name:     spec.Name,
+			Managed:  spec.Managed,
+			Document: json.RawMessage(spec.Document),
+		})
+	}
+
+	logger.Debug().Int("policy_count", len(export.Policies)).Msg("exported role setup")
+	switch format {
+	case ExportFormatTerraform:
+		return export.Terraform()
+	case ExportFormatCloudFormation:
+		return export.CloudFormation()
+	default:
+		return json.MarshalIndent(export, "", "  ")
+	}
+}
+
+var terraformNameReplacer = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// terraformName returns a valid Terraform resource name for an IAM name
+func terraformName(name string) string {
+	name = strings.ToLower(terraformNameReplacer.ReplaceAllString(name, "_"))
+	return "cribl_" + name
+}
+
+// terraformHeredoc returns an indented JSON document as a heredoc, escaping
+// Terraform template sequences
+func terraformHeredoc(document json.RawMessage) (string, error) {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, document, "    ", "  "); err != nil {
+		return "", err
+	}
+	body := strings.NewReplacer("${", "$${", "%{", "%%{").Replace(buf.String())
+	return "<<-EOT\n    " + body + "\n  EOT", nil
+}
+
+// Terraform renders the export as Terraform resources for the AWS provider
+func (e RoleExport) Terraform() ([]byte, error) {
+	var b strings.Builder
+	role := terraformName(e.RoleName)
+
+	trust, err := terraformHeredoc(e.TrustPolicy)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(&b, "resource \"aws_iam_role\" %q {\n", role)
+	fmt.Fprintf(&b, "  name                 = %q\n", e.RoleName)
+	fmt.Fprintf(&b, "  path                 = %q\n", e.Path)
+	fmt.Fprintf(&b, "  description          = %q\n", e.Description)
+	fmt.Fprintf(&b, "  max_session_duration = %d\n", e.MaxSessionDuration)
+	if e.PermissionsBoundary != "" {
+		fmt.Fprintf(&b, "  permissions_boundary = %q\n", e.PermissionsBoundary)
+	}
+	fmt.Fprintf(&b, "  assume_role_policy   = %s\n\n", trust)
+	b.WriteString("  tags = {\n")
+	for _, key := range sortedKeys(e.Tags) {
+		fmt.Fprintf(&b, "    %q = %q\n", key, e.Tags[key])
+	}
+	b.WriteString("  }\n}\n")
+
+	for _, policy := range e.Policies {
+		document, err := terraformHeredoc(policy.Document)
+		if err != nil {
+			return nil, err
+		}
+		name := terraformName(policy.Name)
+		if !policy.Managed {
+			// Inline policy names are only unique per role
+			name = terraformName(e.RoleName + "_" + policy.Name)
+			fmt.Fprintf(&b, "\nresource \"aws_iam_role_policy\" %q {\n", name)
+			fmt.Fprintf(&b, "  name   = %q\n", policy.Name)
+			fmt.Fprintf(&b, "  role   = aws_iam_role.%s.id\n", role)
+			fmt.Fprintf(&b, "  policy = %s\n}\n", document)
+			continue
+		}
+		fmt.Fprintf(&b, "\nresource \"aws_iam_policy\" %q {\n", name)
+		fmt.Fprintf(&b, "  name   = %q\n", policy.Name)
+		fmt.Fprintf(&b, "  policy = %s\n\n", document)
+		b.WriteString("  tags = {\n")
+		for _, key := range sortedKeys(e.Tags) {
+			fmt.Fprintf(&b, "    %q = %q\n", key, e.Tags[key])
+		}
+		b.WriteString("  }\n}\n")
+		fmt.Fprintf(&b, "\nresource \"aws_iam_role_policy_attachment\" %q {\n", name)
+		fmt.Fprintf(&b, "  role       = aws_iam_role.%s.name\n", role)
+		fmt.Fprintf(&b, "  policy_arn = aws_iam_policy.%s.arn\n}\n", name)
+	}
+	return []byte(b.String()), nil
+}
+
+// CloudFormation renders the export as a CloudFormation template in JSON
+func (e RoleExport) CloudFormation() ([]byte, error) {
+	var tags []map[string]string
+	for _, key := range sortedKeys(e.Tags) {
+		tags = append(tags, map[string]string{"Key": key, "Value": e.Tags[key]})
+	}
+
+	var inline []map[string]interface{}
+	resources := map[string]interface{}{}
+	for i, policy := range e.Policies {
+		if !policy.Managed {
+			inline = append(inline, map[string]interface{}{
+				"PolicyName":     policy.Name,
+				"PolicyDocument": policy.Document,
+			})
+			continue
+		}
+		resources[fmt.Sprintf("CriblS3Policy%d", i+1)] = map[string]interface{}{
+			"Type": "AWS::IAM::ManagedPolicy",
+			"Properties": map[string]interface{}{
+				"ManagedPolicyName": policy.Name,
+				"PolicyDocument":    policy.Document,
+				"Roles":             []interface{}{map[string]string{"Ref": "CriblRole"}},
+			},
+		}
+	}
+
+	roleProperties := map[string]interface{}{
+		"RoleName":                 e.RoleName,
+		"Path":                     e.Path,
+		"Description":              e.Description,
+		"MaxSessionDuration":       e.MaxSessionDuration,
+		"AssumeRolePolicyDocument": e.TrustPolicy,
+		"Tags":                     tags,
+	}
+	if e.PermissionsBoundary != "" {
+		roleProperties["PermissionsBoundary"] = e.PermissionsBoundary
+	}
+	if len(inline) > 0 {
+		roleProperties["Policies"] = inline
+	}
+	resources["CriblRole"] = map[string]interface{}{
+		"Type":       "AWS::IAM::Role",
+		"Properties": roleProperties,
+	}
+
+	template := map[string]interface{}{
+		"AWSTemplateFormatVersion": "2010-09-09",
+		"Description":              fmt.Sprintf("Cross-account S3 access role %s for Cribl", e.RoleName),
+		"Resources":                resources,
+		"Outputs": map[string]interface{}{
+			"RoleArn": map[string]interface{}{
+				"Value": map[string]interface{}{"Fn::GetAtt": []string{"CriblRole", "Arn"}},
+			},
+		},
+	}
+	return json.MarshalIndent(template, "", "  ")
+}
+
+// sortedKeys returns the keys of m in sorted order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,360 @@
+// pkg/aws/iam_import.go
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// ImportReport describes an existing role in terms of the options iam setup
+// needs to reproduce it
+type ImportReport struct {
+	RoleName          string              `json:"role_name"`
+	RoleARN           string              `json:"role_arn"`
+	Action            string              `json:"action"`
+	Principals        []ImportedPrincipal `json:"principals"`
+	ExternalIDCount   int                 `json:"external_id_count"`
+	ExternalIDs       []string            `json:"-"` // kept out of the output, the IDs are secrets
+	Buckets           []string            `json:"buckets"`
+	KMSKeyARNs        []string            `json:"kms_key_arns"`
+	SkippedResources  []string            `json:"skipped_resources"`
+	Policies          []string            `json:"policies"`
+	AlreadyManaged    bool                `json:"already_managed"`
+	Tagged            bool                `json:"tagged"`
+	UnrecognizedTrust bool                `json:"unrecognized_trust"`
+
+	// LeftoverPolicies are the policies iam setup does not replace, which stay
+	// attached next to its S3 policy until they are removed by hand
+	LeftoverPolicies []string `json:"leftover_policies"`
+}
+
+// ImportedPrincipal is a principal trusted by an imported role
+type ImportedPrincipal struct {
+	ARN         string `json:"arn"`
+	Recognized  bool   `json:"recognized"`
+	AccountID   string `json:"account_id,omitempty"`
+	Workspace   string `json:"workspace,omitempty"`
+	Workergroup string `json:"workergroup,omitempty"`
+	SearchExec  bool   `json:"search_exec,omitempty"`
+	// Ambiguous is set for Cribl roles whose workspace and worker group
+	// cannot be told apart from the role name alone
+	Ambiguous bool `json:"ambiguous,omitempty"`
+}
+
+// ImportRole inspects an existing role, recognises its Cribl principals and
+// extracts the buckets and KMS keys from all of its inline and customer-managed
+// policies. With tag set the role is tagged as managed by this tool, which is
+// refused if a trusted principal does not match a Cribl role pattern unless
+// force is also set.
+func (c *IAMClient) ImportRole(roleName string, tag, force bool) (*ImportReport, error) {
+	logger := c.logger.With().
+		Str("role_name", roleName).
+		Bool("tag", tag).
+		Logger()
+
+	if roleName == "" {
+		return nil, fmt.Errorf("roleName cannot be empty")
+	}
+
+	out, err := c.Client.GetRole(context.TODO(), &iam.GetRoleInput{
+		RoleName: aws.String(roleName),
+	})
+	if err != nil {
+		var noSuchEntity *types.NoSuchEntityException
+		if errors.As(err, &noSuchEntity) {
+			return nil, fmt.Errorf("role '%s' does not exist", roleName)
+		}
+		logger.Error().Err(err).Msg("failed to get IAM role")
+		return nil, fmt.Errorf("failed to get IAM role: %w", err)
+	}
+
+	report, _, err := c.inspectRole(out.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	if tag && !report.AlreadyManaged {
+		if report.UnrecognizedTrust && !force {
+			return report, fmt.Errorf("role '%s' trusts principals that are not Cribl roles; use --force to adopt it anyway", roleName)
+		}
+		_, err := c.Client.TagRole(context.TODO(), &iam.TagRoleInput{
+			RoleName: aws.String(roleName),
+			Tags: []types.Tag{
+				{Key: aws.String(ManagedByTagKey), Value: aws.String(ManagedByTagValue)},
+			},
+		})
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to tag IAM role")
+			return report, fmt.Errorf("failed to tag IAM role '%s': %w", roleName, err)
+		}
+		report.Tagged = true
+		logger.Info().Msg("tagged role as managed by cribl-storage-tool")
+	}
+
+	logger.Info().Int("bucket_count", len(report.Buckets)).Msg("imported role")
+	return report, nil
+}
+
+// inspectRole describes an existing role in terms of its Cribl principals,
+// external IDs, buckets and KMS keys, and returns the merged S3 policy. The
+// action is guessed from the policies unless the role records it in its
+// cribl:action tag. It makes no changes, so list and describe use it as well.
+func (c *IAMClient) inspectRole(role *types.Role) (*ImportReport, RolePolicyDocument, error) {
+	roleName := aws.ToString(role.RoleName)
+	report := &ImportReport{
+		RoleName:       roleName,
+		RoleARN:        aws.ToString(role.Arn),
+		AlreadyManaged: isManagedRole(role),
+	}
+
+	trustDocument, err := decodePolicyDocument(aws.ToString(role.AssumeRolePolicyDocument))
+	if err != nil {
+		return nil, RolePolicyDocument{}, err
+	}
+	var trustPolicy TrustPolicyDocument
+	if err := json.Unmarshal([]byte(trustDocument), &trustPolicy); err != nil {
+		return nil, RolePolicyDocument{}, fmt.Errorf("failed to parse trust policy of role '%s': %w", roleName, err)
+	}
+	for _, arn := range trustedPrincipals(trustPolicy) {
+		imported := ImportedPrincipal{ARN: arn}
+		principal, err := ParseCriblPrincipalARN(arn)
+		switch {
+		case err == nil:
+			imported.Recognized = true
+			imported.AccountID = principal.AccountID
+			imported.Workspace = principal.Workspace
+			imported.Workergroup = principal.Workergroup
+			imported.SearchExec = principal.SearchExec
+		case errors.Is(err, ErrAmbiguousPrincipal):
+			imported.Recognized = true
+			imported.Ambiguous = true
+			imported.AccountID = principal.AccountID
+		default:
+			report.UnrecognizedTrust = true
+		}
+		report.Principals = append(report.Principals, imported)
+	}
+	if len(report.Principals) == 0 {
+		report.UnrecognizedTrust = true
+	}
+	report.ExternalIDs = trustExternalIDs(trustPolicy)
+	report.ExternalIDCount = len(report.ExternalIDs)
+
+	policy, err := c.getAllRolePolicies(roleName, report)
+	if err != nil {
+		return nil, RolePolicyDocument{}, err
+	}
+
+	for _, grant := range GrantsFromPolicy(policy) {
+		if strings.ContainsAny(grant.Bucket, "*?") {
+			report.SkippedResources = append(report.SkippedResources, grant.BucketARN(c.Partition()))
+			continue
+		}
+		report.Buckets = append(report.Buckets, grant.String())
+	}
+	sort.Strings(report.Buckets)
+	report.KMSKeyARNs = kmsKeysFromPolicy(policy)
+	report.Action = importedAction(policy, report.Principals)
+	for _, tag := range role.Tags {
+		// Roles set up by this tool record their action
+		if aws.ToString(tag.Key) == TagAction && ValidateAction(aws.ToString(tag.Value)) == nil {
+			report.Action = aws.ToString(tag.Value)
+		}
+	}
+
+	return report, policy, nil
+}
+
+// getAllRolePolicies merges the inline and customer-managed policies of a role
+// into one document and records their names in report. AWS managed policies
+// are listed but not read, since their grants cannot be reproduced per bucket.
+func (c *IAMClient) getAllRolePolicies(roleName string, report *ImportReport) (RolePolicyDocument, error) {
+	var merged RolePolicyDocument
+
+	inlinePaginator := iam.NewListRolePoliciesPaginator(c.Client, &iam.ListRolePoliciesInput{
+		RoleName: aws.String(roleName),
+	})
+	for inlinePaginator.HasMorePages() {
+		page, err := inlinePaginator.NextPage(context.TODO())
+		if err != nil {
+			return merged, fmt.Errorf("failed to list inline policies for role '%s': %w", roleName, err)
+		}
+		for _, policyName := range page.PolicyNames {
+			document, err := c.getRolePolicy(roleName, policyName)
+			if err != nil {
+				return merged, err
+			}
+			if err := mergePolicyDocument(&merged, document); err != nil {
+				return merged, fmt.Errorf("failed to parse policy '%s' of role '%s': %w", policyName, roleName, err)
+			}
+			report.Policies = append(report.Policies, "inline:"+policyName)
+			if policyName != S3PolicyName {
+				report.LeftoverPolicies = append(report.LeftoverPolicies, "inline:"+policyName)
+			}
+		}
+	}
+
+	attachedPaginator := iam.NewListAttachedRolePoliciesPaginator(c.Client, &iam.ListAttachedRolePoliciesInput{
+		RoleName: aws.String(roleName),
+	})
+	for attachedPaginator.HasMorePages() {
+		page, err := attachedPaginator.NextPage(context.TODO())
+		if err != nil {
+			return merged, fmt.Errorf("failed to list attached policies for role '%s': %w", roleName, err)
+		}
+		for _, policy := range page.AttachedPolicies {
+			policyARN := aws.ToString(policy.PolicyArn)
+			if strings.Contains(policyARN, ":iam::aws:policy/") {
+				report.Policies = append(report.Policies, "aws-managed:"+aws.ToString(policy.PolicyName))
+				report.LeftoverPolicies = append(report.LeftoverPolicies, "aws-managed:"+aws.ToString(policy.PolicyName))
+				continue
+			}
+			document, err := c.getManagedPolicyDocument(policyARN)
+			if err != nil {
+				return merged, err
+			}
+			if err := mergePolicyDocument(&merged, document); err != nil {
+				return merged, fmt.Errorf("failed to parse policy '%s': %w", policyARN, err)
+			}
+			report.Policies = append(report.Policies, "managed:"+aws.ToString(policy.PolicyName))
+			if _, ok := splitPolicyIndex(roleName, aws.ToString(policy.PolicyName)); !ok {
+				report.LeftoverPolicies = append(report.LeftoverPolicies, "managed:"+aws.ToString(policy.PolicyName))
+			}
+		}
+	}
+	return merged, nil
+}
+
+// mergePolicyDocument appends the statements of a policy document to merged.
+// Hand-written policies may hold a single statement object instead of a list.
+func mergePolicyDocument(merged *RolePolicyDocument, document string) error {
+	if document == "" {
+		return nil
+	}
+	var policy RolePolicyDocument
+	if err := json.Unmarshal([]byte(document), &policy); err == nil {
+		merged.Statement = append(merged.Statement, policy.Statement...)
+		return nil
+	}
+	var single struct {
+		Statement RoleStatement `json:"Statement"`
+	}
+	if err := json.Unmarshal([]byte(document), &single); err != nil {
+		return err
+	}
+	merged.Statement = append(merged.Statement, single.Statement)
+	return nil
+}
+
+// importedAction guesses the Cribl action a role is used for: send if it can
+// write objects, search if it trusts a search-exec role, collect otherwise
+func importedAction(doc RolePolicyDocument, principals []ImportedPrincipal) string {
+	for _, statement := range doc.Statement {
+		if statement.Effect != "Allow" {
+			continue
+		}
+		for _, action := range statement.Action {
+			if strings.EqualFold(action, "s3:PutObject") {
+				return ActionSend
+			}
+		}
+	}
+	for _, principal := range principals {
+		if principal.SearchExec {
+			return ActionSearch
+		}
+	}
+	return ActionCollect
+}
+
+// SetupCommand returns the iam setup command line that reproduces the role.
+// The external ID is left as a placeholder so it is not printed to the terminal.
+func (r *ImportReport) SetupCommand(bucketFile string) string {
+	args := []string{"cribl-storage-tool", "iam", "setup", "-r", r.RoleName, "-s", r.Action}
+	ambiguous := false
+	for _, principal := range r.Principals {
+		if principal.Recognized {
+			args = append(args, "--cribl-worker-arn", principal.ARN)
+		}
+		ambiguous = ambiguous || principal.Ambiguous
+	}
+	if ambiguous {
+		args = append(args, "-w", "<WORKSPACE>")
+	}
+	if len(r.ExternalIDs) > 0 {
+		args = append(args, "-e", "<EXTERNAL_ID>")
+	} else {
+		args = append(args, "--allow-empty-external-id")
+	}
+	if bucketFile != "" {
+		args = append(args, "-f", bucketFile)
+	} else {
+		for _, bucket := range r.Buckets {
+			args = append(args, "-b", bucket)
+		}
+	}
+	for _, keyARN := range r.KMSKeyARNs {
+		args = append(args, "--kms-key-arn", keyARN)
+	}
+	return strings.Join(args, " ")
+}
+
+// PrintText prints the import report in a human-readable format
+func (r *ImportReport) PrintText(bucketFile string) {
+	fmt.Printf("Role %q (%s):\n", r.RoleName, r.RoleARN)
+	fmt.Printf("  action: %s\n", r.Action)
+	for _, principal := range r.Principals {
+		switch {
+		case principal.Ambiguous:
+			fmt.Printf("  principal %s (recognized, pass --workspace to setup to tell the workspace from the worker group)\n", principal.ARN)
+		case principal.Recognized:
+			fmt.Printf("  principal %s (recognized)\n", principal.ARN)
+		default:
+			fmt.Printf("  principal %s (not a Cribl role pattern)\n", principal.ARN)
+		}
+	}
+	fmt.Printf("  external IDs: %d\n", len(r.ExternalIDs))
+	for _, bucket := range r.Buckets {
+		fmt.Printf("  bucket %s\n", bucket)
+	}
+	for _, keyARN := range r.KMSKeyARNs {
+		fmt.Printf("  kms key %s\n", keyARN)
+	}
+	for _, resource := range r.SkippedResources {
+		fmt.Printf("  ! skipped wildcard resource %s\n", resource)
+	}
+	for _, policy := range r.Policies {
+		fmt.Printf("  policy %s\n", policy)
+	}
+	switch {
+	case r.Tagged:
+		fmt.Println("  tagged as managed by cribl-storage-tool")
+	case r.AlreadyManaged:
+		fmt.Println("  already managed by cribl-storage-tool")
+	}
+	fmt.Printf("\nReproduce with:\n  %s\n", r.SetupCommand(bucketFile))
+	if len(r.LeftoverPolicies) > 0 {
+		fmt.Println("\nSetup adds its own S3 policy and leaves these attached; once it succeeds, remove them by hand:")
+		for _, policy := range r.LeftoverPolicies {
+			fmt.Printf("  ! %s\n", policy)
+		}
+	}
+}
+
+// PrintJSON prints the import report in JSON format
+func (r *ImportReport) PrintJSON() error {
+	jsonData, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(jsonData))
+	return nil
+}
@@ -0,0 +1,244 @@
+// pkg/aws/iam_list.go
+package aws
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// listWorkers is the number of roles inspected concurrently by ListCriblRoles
+const listWorkers = 8
+
+// RoleSummary is one Cribl cross-account role found in the account
+type RoleSummary struct {
+	RoleName        string   `json:"role_name"`
+	RoleARN         string   `json:"role_arn"`
+	Managed         bool     `json:"managed"`
+	TrustedAccounts []string `json:"trusted_accounts"`
+	Workspaces      []string `json:"workspaces"`
+	Workergroups    []string `json:"workergroups"`
+	Action          string   `json:"action"`
+	ExternalID      bool     `json:"external_id"`
+	Buckets         []string `json:"buckets"`
+	// Error is set when the role could not be fully inspected
+	Error string `json:"error,omitempty"`
+}
+
+// ListCriblRoles returns the roles whose trust policy trusts a Cribl principal
+// or that are tagged as managed by this tool, optionally limited to a path prefix
+func (c *IAMClient) ListCriblRoles(pathPrefix string) ([]RoleSummary, error) {
+	logger := c.logger.With().Str("path_prefix", pathPrefix).Logger()
+
+	input := &iam.ListRolesInput{}
+	if pathPrefix != "" {
+		input.PathPrefix = aws.String(pathPrefix)
+	}
+
+	var roles []types.Role
+	paginator := iam.NewListRolesPaginator(c.Client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to list roles")
+			return nil, fmt.Errorf("failed to list IAM roles: %w", err)
+		}
+		roles = append(roles, page.Roles...)
+	}
+	logger.Debug().Int("role_count", len(roles)).Msg("listed roles")
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		summaries []RoleSummary
+	)
+	jobs := make(chan types.Role)
+	for i := 0; i < listWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for role := range jobs {
+				summary, ok := c.summarizeRole(role)
+				if !ok {
+					continue
+				}
+				mu.Lock()
+				summaries = append(summaries, summary)
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, role := range roles {
+		jobs <- role
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].RoleName < summaries[j].RoleName
+	})
+	logger.Info().Int("cribl_role_count", len(summaries)).Msg("found Cribl roles")
+	return summaries, nil
+}
+
+// summarizeRole reports whether a role is a Cribl role and summarises it.
+// ListRoles does not return tags, so every role is fetched with GetRole to
+// check the managed-by tag. A role that cannot be fetched or inspected is
+// reported with its error instead of failing the whole inventory, since it may
+// be a tagged Cribl role.
+func (c *IAMClient) summarizeRole(role types.Role) (RoleSummary, bool) {
+	roleName := aws.ToString(role.RoleName)
+	logger := c.logger.With().Str("role_name", roleName).Logger()
+	summary := RoleSummary{
+		RoleName:        roleName,
+		RoleARN:         aws.ToString(role.Arn),
+		TrustedAccounts: []string{},
+		Workspaces:      []string{},
+		Workergroups:    []string{},
+		Buckets:         []string{},
+	}
+
+	criblTrust := false
+	document, err := decodePolicyDocument(aws.ToString(role.AssumeRolePolicyDocument))
+	var trustPolicy TrustPolicyDocument
+	if err == nil && json.Unmarshal([]byte(document), &trustPolicy) == nil {
+		for _, arn := range trustedPrincipals(trustPolicy) {
+			if _, err := ParseCriblPrincipalARN(arn); err == nil || errors.Is(err, ErrAmbiguousPrincipal) {
+				criblTrust = true
+				break
+			}
+		}
+	}
+
+	out, err := c.Client.GetRole(context.TODO(), &iam.GetRoleInput{
+		RoleName: aws.String(roleName),
+	})
+	if err != nil {
+		logger.Warn().Err(err).Msg("failed to get IAM role")
+		summary.Error = fmt.Sprintf("failed to get IAM role: %v", err)
+		if !criblTrust {
+			summary.Error = fmt.Sprintf("failed to get IAM role to check the managed-by tag: %v", err)
+		}
+		return summary, true
+	}
+	if !criblTrust && !isManagedRole(out.Role) {
+		return RoleSummary{}, false
+	}
+
+	report, _, err := c.inspectRole(out.Role)
+	if err != nil {
+		logger.Warn().Err(err).Msg("failed to inspect IAM role")
+		summary.Managed = isManagedRole(out.Role)
+		summary.Error = err.Error()
+		return summary, true
+	}
+
+	summary.Managed = report.AlreadyManaged
+	summary.Action = report.Action
+	summary.ExternalID = len(report.ExternalIDs) > 0
+	if report.Buckets != nil {
+		summary.Buckets = report.Buckets
+	}
+	for _, principal := range report.Principals {
+		if !principal.Recognized {
+			continue
+		}
+		summary.TrustedAccounts = appendUniqueStrings(summary.TrustedAccounts, principal.AccountID)
+		if principal.Workspace != "" {
+			summary.Workspaces = appendUniqueStrings(summary.Workspaces, principal.Workspace)
+		}
+		if principal.Workergroup != "" {
+			summary.Workergroups = appendUniqueStrings(summary.Workergroups, principal.Workergroup)
+		}
+	}
+	return summary, true
+}
+
+// PrintRoleSummariesText prints the roles as a table
+func PrintRoleSummariesText(summaries []RoleSummary) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ROLE\tACCOUNT\tWORKSPACE\tWORKERGROUP\tACTION\tEXTERNAL ID\tMANAGED\tBUCKETS")
+	for _, s := range summaries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			s.RoleName,
+			listOrDash(s.TrustedAccounts),
+			listOrDash(s.Workspaces),
+			listOrDash(s.Workergroups),
+			valueOrDash(s.Action),
+			yesNo(s.ExternalID),
+			yesNo(s.Managed),
+			listOrDash(s.Buckets))
+	}
+	w.Flush()
+	for _, s := range summaries {
+		if s.Error != "" {
+			fmt.Printf("! %s: %s\n", s.RoleName, s.Error)
+		}
+	}
+	fmt.Printf("Found %d Cribl role(s)\n", len(summaries))
+}
+
+// PrintRoleSummariesJSON prints the roles in JSON format
+func PrintRoleSummariesJSON(summaries []RoleSummary) error {
+	jsonData, err := json.MarshalIndent(summaries, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(jsonData))
+	return nil
+}
+
+// PrintRoleSummariesCSV prints the roles as CSV. List fields are separated by
+// semicolons.
+func PrintRoleSummariesCSV(summaries []RoleSummary) error {
+	w := csv.NewWriter(os.Stdout)
+	header := []string{"role_name", "role_arn", "trusted_accounts", "workspaces", "workergroups", "action", "external_id", "managed", "buckets", "error"}
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	for _, s := range summaries {
+		record := []string{
+			s.RoleName,
+			s.RoleARN,
+			strings.Join(s.TrustedAccounts, ";"),
+			strings.Join(s.Workspaces, ";"),
+			strings.Join(s.Workergroups, ";"),
+			s.Action,
+			strconv.FormatBool(s.ExternalID),
+			strconv.FormatBool(s.Managed),
+			strings.Join(s.Buckets, ";"),
+			s.Error,
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func listOrDash(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return strings.Join(values, ",")
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,479 @@
+// pkg/aws/iam_plan.go
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// Plan actions for a single resource
+const (
+	PlanActionCreate   = "create"
+	PlanActionUpdate   = "update"
+	PlanActionDelete   = "delete"
+	PlanActionNoChange = "no-op"
+)
+
+// Plan describes the IAM changes SetupTrustRelationship would make
+type Plan struct {
+	RoleName string           `json:"role_name"`
+	Changes  []ResourceChange `json:"changes"`
+}
+
+// ResourceChange describes the change to one IAM document
+type ResourceChange struct {
+	Resource string          `json:"resource"`
+	Action   string          `json:"action"`
+	Current  json.RawMessage `json:"current,omitempty"`
+	Desired  json.RawMessage `json:"desired,omitempty"`
+	Diffs    []FieldDiff     `json:"diffs,omitempty"`
+}
+
+// FieldDiff is a single difference between the current and desired document
+type FieldDiff struct {
+	Path string      `json:"path"`
+	Op   string      `json:"op"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// Field diff operations
+const (
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+	DiffChanged = "changed"
+)
+
+// PlanTrustRelationship computes the changes SetupTrustRelationship would make
+// for opts without modifying anything in the account.
+func (c *IAMClient) PlanTrustRelationship(opts SetupOptions) (*Plan, error) {
+	logger := c.logger.With().
+		Str("role_name", opts.RoleName).
+		Str("action", opts.Action).
+		Logger()
+
+	if err := c.validateInputs(opts); err != nil {
+		logger.Error().Err(err).Msg("input validation failed")
+		return nil, err
+	}
+
+	plan := &Plan{RoleName: opts.RoleName}
+
+	role, err := c.getRole(opts.RoleName)
+	if err != nil {
+		return nil, err
+	}
+	change, err := planRoleSettings(role, opts)
+	if err != nil {
+		return nil, err
+	}
+	plan.Changes = append(plan.Changes, change)
+
+	principalARNs, err := c.resolvePrincipals(opts)
+	if err != nil {
+		return nil, err
+	}
+	trustPolicy := c.createTrustPolicy(principalARNs, opts.ExternalID)
+	currentTrust := ""
+	if role != nil {
+		if currentTrust, err = decodePolicyDocument(aws.ToString(role.AssumeRolePolicyDocument)); err != nil {
+			return nil, err
+		}
+	}
+	change, err = newResourceChange("trust_policy", currentTrust, trustPolicy)
+	if err != nil {
+		return nil, err
+	}
+	plan.Changes = append(plan.Changes, change)
+
+	grants, kmsKeyARNs, err := c.resolveGrants(opts)
+	if err != nil {
+		return nil, err
+	}
+	budget, err := c.availablePolicyBudget(opts.RoleName)
+	if err != nil {
+		return nil, err
+	}
+	specs, err := c.buildS3Policies(opts.RoleName, opts.Action, grants, kmsKeyARNs, opts.CompactWildcards, budget)
+	if err != nil {
+		return nil, err
+	}
+	s3Changes, err := c.planS3Policies(opts.RoleName, role != nil, specs)
+	if err != nil {
+		return nil, err
+	}
+	plan.Changes = append(plan.Changes, s3Changes...)
+
+	logger.Debug().Msg("computed plan")
+	return plan, nil
+}
+
+// planS3Policies compares the desired S3 policies with the inline and managed
+// policies on the role, including policies that would be removed
+func (c *IAMClient) planS3Policies(roleName string, roleExists bool, specs []s3PolicySpec) ([]ResourceChange, error) {
+	var changes []ResourceChange
+
+	var roleARN string
+	var attached []types.AttachedPolicy
+	currentInline := ""
+	if roleExists {
+		var err error
+		if roleARN, err = c.GetRoleARN(roleName); err != nil {
+			return nil, err
+		}
+		if attached, err = c.listSplitPolicies(roleName); err != nil {
+			return nil, err
+		}
+		if currentInline, err = c.getRolePolicy(roleName, S3PolicyName); err != nil {
+			return nil, err
+		}
+	}
+
+	desiredManaged := make(map[string]bool)
+	for _, spec := range specs {
+		if !spec.Managed {
+			change, err := newResourceChange("inline_policy:"+spec.Name, currentInline, spec.Document)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, change)
+			continue
+		}
+
+		desiredManaged[spec.Name] = true
+		current := ""
+		if roleExists {
+			var err error
+			if current, err = c.getManagedPolicyDocument(managedPolicyARN(roleARN, spec.Name)); err != nil {
+				return nil, err
+			}
+		}
+		change, err := newResourceChange("managed_policy:"+spec.Name, current, spec.Document)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+
+	if specs[0].Managed && currentInline != "" {
+		changes = append(changes, deleteResourceChange("inline_policy:"+S3PolicyName, currentInline))
+	}
+	for _, policy := range attached {
+		name := aws.ToString(policy.PolicyName)
+		if desiredManaged[name] {
+			continue
+		}
+		current, err := c.getManagedPolicyDocument(aws.ToString(policy.PolicyArn))
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, deleteResourceChange("managed_policy:"+name, current))
+	}
+	return changes, nil
+}
+
+// getRole returns a role, or nil if the role does not exist.
+func (c *IAMClient) getRole(roleName string) (*types.Role, error) {
+	out, err := c.Client.GetRole(context.TODO(), &iam.GetRoleInput{
+		RoleName: aws.String(roleName),
+	})
+	if err != nil {
+		var noSuchEntity *types.NoSuchEntityException
+		if errors.As(err, &noSuchEntity) {
+			return nil, nil
+		}
+		c.logger.Error().Err(err).Str("role_name", roleName).Msg("failed to get IAM role")
+		return nil, fmt.Errorf("failed to get IAM role: %w", err)
+	}
+	return out.Role, nil
+}
+
+// getTrustPolicy returns the decoded trust policy of a role, or an empty string
+// if the role does not exist.
+func (c *IAMClient) getTrustPolicy(roleName string) (string, error) {
+	role, err := c.getRole(roleName)
+	if err != nil || role == nil {
+		return "", err
+	}
+	return decodePolicyDocument(aws.ToString(role.AssumeRolePolicyDocument))
+}
+
+// planRoleSettings compares the settings of the role with the desired ones
+func planRoleSettings(role *types.Role, opts SetupOptions) (ResourceChange, error) {
+	var current *RoleSettings
+	currentJSON := ""
+	if role != nil {
+		settings := currentRoleSettings(role)
+		current = &settings
+		data, err := json.Marshal(settings)
+		if err != nil {
+			return ResourceChange{}, err
+		}
+		currentJSON = string(data)
+	}
+	desiredJSON, err := json.Marshal(desiredRoleSettings(opts, current))
+	if err != nil {
+		return ResourceChange{}, err
+	}
+	return newResourceChange("role", currentJSON, string(desiredJSON))
+}
+
+// getRolePolicy returns the decoded inline policy of a role, or an empty string
+// if the policy does not exist.
+func (c *IAMClient) getRolePolicy(roleName, policyName string) (string, error) {
+	out, err := c.Client.GetRolePolicy(context.TODO(), &iam.GetRolePolicyInput{
+		RoleName:   aws.String(roleName),
+		PolicyName: aws.String(policyName),
+	})
+	if err != nil {
+		var noSuchEntity *types.NoSuchEntityException
+		if errors.As(err, &noSuchEntity) {
+			return "", nil
+		}
+		c.logger.Error().Err(err).
+			Str("role_name", roleName).
+			Str("policy_name", policyName).
+			Msg("failed to get inline policy")
+		return "", fmt.Errorf("failed to get inline policy '%s' for role '%s': %w", policyName, roleName, err)
+	}
+	return decodePolicyDocument(aws.ToString(out.PolicyDocument))
+}
+
+// decodePolicyDocument URL-decodes a policy document as returned by the IAM API
+func decodePolicyDocument(document string) (string, error) {
+	decoded, err := url.QueryUnescape(document)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode policy document: %w", err)
+	}
+	return decoded, nil
+}
+
+func deleteResourceChange(resource, current string) ResourceChange {
+	return ResourceChange{
+		Resource: resource,
+		Action:   PlanActionDelete,
+		Current:  json.RawMessage(current),
+	}
+}
+
+func newResourceChange(resource, current, desired string) (ResourceChange, error) {
+	change := ResourceChange{
+		Resource: resource,
+		Desired:  json.RawMessage(desired),
+	}
+	if current == "" {
+		change.Action = PlanActionCreate
+		return change, nil
+	}
+	change.Current = json.RawMessage(current)
+
+	var currentValue, desiredValue interface{}
+	if err := json.Unmarshal([]byte(current), &currentValue); err != nil {
+		return change, fmt.Errorf("failed to parse current %s: %w", resource, err)
+	}
+	if err := json.Unmarshal([]byte(desired), &desiredValue); err != nil {
+		return change, fmt.Errorf("failed to parse desired %s: %w", resource, err)
+	}
+
+	change.Diffs = diffValues("", currentValue, desiredValue)
+	if len(change.Diffs) == 0 {
+		change.Action = PlanActionNoChange
+	} else {
+		change.Action = PlanActionUpdate
+	}
+	return change, nil
+}
+
+// diffValues walks two decoded JSON values and returns their differences.
+// Arrays of scalars are compared as sets since IAM does not preserve order.
+func diffValues(path string, current, desired interface{}) []FieldDiff {
+	switch cur := current.(type) {
+	case map[string]interface{}:
+		des, ok := desired.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make(map[string]struct{})
+		for k := range cur {
+			keys[k] = struct{}{}
+		}
+		for k := range des {
+			keys[k] = struct{}{}
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+
+		var diffs []FieldDiff
+		for _, k := range sorted {
+			childPath := joinPath(path, k)
+			curValue, inCur := cur[k]
+			desValue, inDes := des[k]
+			switch {
+			case !inCur:
+				diffs = append(diffs, FieldDiff{Path: childPath, Op: DiffAdded, New: desValue})
+			case !inDes:
+				diffs = append(diffs, FieldDiff{Path: childPath, Op: DiffRemoved, Old: curValue})
+			default:
+				diffs = append(diffs, diffValues(childPath, curValue, desValue)...)
+			}
+		}
+		return diffs
+	case []interface{}:
+		des, ok := desired.([]interface{})
+		if !ok {
+			// IAM accepts a single string wherever a list is allowed
+			if s, isString := desired.(string); isString {
+				des = []interface{}{s}
+			} else {
+				break
+			}
+		}
+		if isScalarList(cur) && isScalarList(des) {
+			return diffScalarLists(path, cur, des)
+		}
+		var diffs []FieldDiff
+		for i := 0; i < len(cur) || i < len(des); i++ {
+			childPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(cur):
+				diffs = append(diffs, FieldDiff{Path: childPath, Op: DiffAdded, New: des[i]})
+			case i >= len(des):
+				diffs = append(diffs, FieldDiff{Path: childPath, Op: DiffRemoved, Old: cur[i]})
+			default:
+				diffs = append(diffs, diffValues(childPath, cur[i], des[i])...)
+			}
+		}
+		return diffs
+	case string:
+		if des, ok := desired.([]interface{}); ok && isScalarList(des) {
+			return diffScalarLists(path, []interface{}{cur}, des)
+		}
+	}
+
+	if !reflect.DeepEqual(current, desired) {
+		return []FieldDiff{{Path: path, Op: DiffChanged, Old: current, New: desired}}
+	}
+	return nil
+}
+
+func diffScalarLists(path string, current, desired []interface{}) []FieldDiff {
+	var diffs []FieldDiff
+	for _, v := range current {
+		if !containsValue(desired, v) {
+			diffs = append(diffs, FieldDiff{Path: path, Op: DiffRemoved, Old: v})
+		}
+	}
+	for _, v := range desired {
+		if !containsValue(current, v) {
+			diffs = append(diffs, FieldDiff{Path: path, Op: DiffAdded, New: v})
+		}
+	}
+	return diffs
+}
+
+func isScalarList(values []interface{}) bool {
+	for _, v := range values {
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			return false
+		}
+	}
+	return true
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if reflect.DeepEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// PrintText prints the plan in a human-readable format
+func (p *Plan) PrintText() {
+	fmt.Printf("Plan for role %q:\n", p.RoleName)
+
+	counts := make(map[string]int)
+	for _, change := range p.Changes {
+		counts[change.Action]++
+		switch change.Action {
+		case PlanActionCreate:
+			fmt.Printf("  + %s (create)\n", change.Resource)
+			fmt.Println(indentJSON(change.Desired, "      "))
+		case PlanActionDelete:
+			fmt.Printf("  - %s (delete)\n", change.Resource)
+		case PlanActionUpdate:
+			fmt.Printf("  ~ %s (update)\n", change.Resource)
+			for _, diff := range change.Diffs {
+				fmt.Printf("      %s\n", diff.String())
+			}
+		default:
+			fmt.Printf("  = %s (no changes)\n", change.Resource)
+		}
+	}
+
+	fmt.Printf("Plan: %d to create, %d to update, %d to delete, %d unchanged.\n",
+		counts[PlanActionCreate], counts[PlanActionUpdate], counts[PlanActionDelete], counts[PlanActionNoChange])
+}
+
+// PrintJSON prints the plan in JSON format
+func (p *Plan) PrintJSON() error {
+	jsonData, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(jsonData))
+	return nil
+}
+
+func (d FieldDiff) String() string {
+	switch d.Op {
+	case DiffAdded:
+		return fmt.Sprintf("+ %s: %s", d.Path, formatValue(d.New))
+	case DiffRemoved:
+		return fmt.Sprintf("- %s: %s", d.Path, formatValue(d.Old))
+	default:
+		return fmt.Sprintf("~ %s: %s => %s", d.Path, formatValue(d.Old), formatValue(d.New))
+	}
+}
+
+func formatValue(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
+
+func indentJSON(raw json.RawMessage, prefix string) string {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return prefix + string(raw)
+	}
+	data, err := json.MarshalIndent(value, prefix, "  ")
+	if err != nil {
+		return prefix + string(raw)
+	}
+	return prefix + strings.TrimRight(string(data), "\n")
+}
@@ -0,0 +1,136 @@
+// pkg/aws/iam_role_settings.go
+package aws
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// IAM defaults and limits for role settings
+const (
+	defaultRolePath           = "/"
+	defaultMaxSessionDuration = 3600
+	minMaxSessionDuration     = 3600
+	maxMaxSessionDuration     = 43200
+	maxRolePathLength         = 512
+	maxDescriptionLength      = 1000
+)
+
+var rolePathPattern = regexp.MustCompile(`^/([\x21-\x7E]+/)*$`)
+
+// RoleSettings are the role properties iam setup manages besides its policies
+type RoleSettings struct {
+	Path                string `json:"Path"`
+	Description         string `json:"Description"`
+	MaxSessionDuration  int32  `json:"MaxSessionDuration"`
+	PermissionsBoundary string `json:"PermissionsBoundary,omitempty"`
+}
+
+// validateRoleSettings checks the role settings in opts against the IAM limits
+func validateRoleSettings(opts SetupOptions) error {
+	if opts.Path != "" {
+		if len(opts.Path) > maxRolePathLength || !rolePathPattern.MatchString(opts.Path) {
+			return fmt.Errorf("invalid path '%s': must begin and end with '/' and be at most %d characters", opts.Path, maxRolePathLength)
+		}
+	}
+	if opts.MaxSessionDuration != 0 && (opts.MaxSessionDuration < minMaxSessionDuration || opts.MaxSessionDuration > maxMaxSessionDuration) {
+		return fmt.Errorf("invalid max session duration %d: must be between %d and %d seconds", opts.MaxSessionDuration, minMaxSessionDuration, maxMaxSessionDuration)
+	}
+	if len(opts.Description) > maxDescriptionLength {
+		return fmt.Errorf("invalid description: must be at most %d characters", maxDescriptionLength)
+	}
+	if opts.PermissionsBoundary != "" {
+		parts := strings.SplitN(opts.PermissionsBoundary, ":", 6)
+		if len(parts) != 6 || parts[0] != "arn" || parts[2] != "iam" || !strings.HasPrefix(parts[5], "policy/") {
+			return fmt.Errorf("invalid permissions boundary '%s': expected a managed policy ARN", opts.PermissionsBoundary)
+		}
+	}
+	return nil
+}
+
+// currentRoleSettings returns the settings of an existing role
+func currentRoleSettings(role *types.Role) RoleSettings {
+	settings := RoleSettings{
+		Path:               aws.ToString(role.Path),
+		Description:        aws.ToString(role.Description),
+		MaxSessionDuration: aws.ToInt32(role.MaxSessionDuration),
+	}
+	if role.PermissionsBoundary != nil {
+		settings.PermissionsBoundary = aws.ToString(role.PermissionsBoundary.PermissionsBoundaryArn)
+	}
+	return settings
+}
+
+// desiredRoleSettings returns the settings the role should have. Settings not
+// given in opts keep their current value, or the IAM default for new roles.
+func desiredRoleSettings(opts SetupOptions, current *RoleSettings) RoleSettings {
+	settings := RoleSettings{
+		Path:               defaultRolePath,
+		Description:        roleDescription,
+		MaxSessionDuration: defaultMaxSessionDuration,
+	}
+	if current != nil {
+		settings = *current
+	}
+	if opts.Path != "" {
+		settings.Path = opts.Path
+	}
+	if opts.Description != "" {
+		settings.Description = opts.Description
+	}
+	if opts.MaxSessionDuration != 0 {
+		settings.MaxSessionDuration = opts.MaxSessionDuration
+	}
+	if opts.PermissionsBoundary != "" {
+		settings.PermissionsBoundary = opts.PermissionsBoundary
+	}
+	return settings
+}
+
+// reconcileRoleSettings updates the description, max session duration and
+// permissions boundary of an existing role. The path of a role cannot be
+// changed, so a different path is reported as an error.
+func (c *IAMClient) reconcileRoleSettings(role *types.Role, opts SetupOptions) error {
+	roleName := aws.ToString(role.RoleName)
+	logger := c.logger.With().Str("role_name", roleName).Logger()
+
+	current := currentRoleSettings(role)
+	desired := desiredRoleSettings(opts, &current)
+
+	if desired.Path != current.Path {
+		logger.Error().Str("path", current.Path).Str("desired_path", desired.Path).Msg("role path differs")
+		return fmt.Errorf("role '%s' exists with path '%s'; the path of a role cannot be changed, delete it with iam teardown first", roleName, current.Path)
+	}
+
+	if desired.Description != current.Description || desired.MaxSessionDuration != current.MaxSessionDuration {
+		_, err := c.Client.UpdateRole(context.TODO(), &iam.UpdateRoleInput{
+			RoleName:           aws.String(roleName),
+			Description:        aws.String(desired.Description),
+			MaxSessionDuration: aws.Int32(desired.MaxSessionDuration),
+		})
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to update IAM role")
+			return fmt.Errorf("failed to update IAM role '%s': %w", roleName, err)
+		}
+		logger.Info().Msg("updated role description and max session duration")
+	}
+
+	if desired.PermissionsBoundary != current.PermissionsBoundary {
+		_, err := c.Client.PutRolePermissionsBoundary(context.TODO(), &iam.PutRolePermissionsBoundaryInput{
+			RoleName:            aws.String(roleName),
+			PermissionsBoundary: aws.String(desired.PermissionsBoundary),
+		})
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to set permissions boundary")
+			return fmt.Errorf("failed to set permissions boundary of IAM role '%s': %w", roleName, err)
+		}
+		logger.Info().Str("permissions_boundary", desired.PermissionsBoundary).Msg("updated role permissions boundary")
+	}
+	return nil
+}
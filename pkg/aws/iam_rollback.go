@@ -0,0 +1,278 @@
+// pkg/aws/iam_rollback.go
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// roleSnapshot is the state of a role before SetupTrustRelationship changes it
+type roleSnapshot struct {
+	RoleName    string
+	Existed     bool
+	Settings    RoleSettings
+	TrustPolicy string
+	Tags        map[string]string
+	// InlinePolicy is the inline S3 policy, empty if the role had none
+	InlinePolicy string
+	// ManagedPolicies maps the names of the role's managed S3 policies to their
+	// default version
+	ManagedPolicies map[string]string
+}
+
+// RollbackError is returned when setup failed part way and the role was
+// restored to its earlier state, or deleted if setup had created it
+type RollbackError struct {
+	// Err is the error that made setup fail
+	Err error
+	// RolledBack lists the changes that were undone
+	RolledBack []string
+	// RollbackErr is set if restoring the role failed as well
+	RollbackErr error
+}
+
+func (e *RollbackError) Error() string {
+	msg := e.Err.Error()
+	if len(e.RolledBack) > 0 {
+		msg += "; rolled back: " + strings.Join(e.RolledBack, ", ")
+	}
+	if e.RollbackErr != nil {
+		msg += "; rollback incomplete, the role may be left partially updated: " + e.RollbackErr.Error()
+	}
+	return msg
+}
+
+func (e *RollbackError) Unwrap() error {
+	return e.Err
+}
+
+// snapshotRole records the settings, trust policy, tags and S3 policies of a
+// role so that a failed setup can restore them
+func (c *IAMClient) snapshotRole(roleName string) (*roleSnapshot, error) {
+	snapshot := &roleSnapshot{
+		RoleName:        roleName,
+		Tags:            map[string]string{},
+		ManagedPolicies: map[string]string{},
+	}
+
+	role, err := c.getRole(roleName)
+	if err != nil || role == nil {
+		return snapshot, err
+	}
+	snapshot.Existed = true
+	snapshot.Settings = currentRoleSettings(role)
+	for _, tag := range role.Tags {
+		snapshot.Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+
+	snapshot.TrustPolicy, err = decodePolicyDocument(aws.ToString(role.AssumeRolePolicyDocument))
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot.InlinePolicy, err = c.getRolePolicy(roleName, S3PolicyName)
+	if err != nil {
+		return nil, err
+	}
+
+	policies, err := c.listSplitPolicies(roleName)
+	if err != nil {
+		return nil, err
+	}
+	for _, policy := range policies {
+		document, err := c.getManagedPolicyDocument(aws.ToString(policy.PolicyArn))
+		if err != nil {
+			return nil, err
+		}
+		snapshot.ManagedPolicies[aws.ToString(policy.PolicyName)] = document
+	}
+
+	c.logger.Debug().
+		Str("role_name", roleName).
+		Int("managed_policy_count", len(snapshot.ManagedPolicies)).
+		Msg("took role snapshot")
+	return snapshot, nil
+}
+
+// rollbackSetup undoes a failed setup. A role created by the failed run, as
+// reported by created, is deleted; an existing role gets its S3 policies, trust
+// policy, settings and tags restored from the snapshot. A role that appeared
+// after the snapshot was created by someone else and is left alone. The
+// original error is returned unchanged if there was nothing to undo.
+func (c *IAMClient) rollbackSetup(snapshot *roleSnapshot, created bool, setupErr error) error {
+	logger := c.logger.With().Str("role_name", snapshot.RoleName).Logger()
+	logger.Warn().Err(setupErr).Msg("setup failed, rolling back")
+
+	var rolledBack []string
+	var rollbackErr error
+	switch {
+	case created:
+		if err := c.TeardownRole(snapshot.RoleName, true); err != nil {
+			rollbackErr = err
+		} else {
+			rolledBack = append(rolledBack, "deleted newly created role")
+		}
+	case snapshot.Existed:
+		rolledBack, rollbackErr = c.restoreRole(snapshot)
+	default:
+		role, err := c.getRole(snapshot.RoleName)
+		switch {
+		case err != nil:
+			rollbackErr = err
+		case role != nil:
+			rollbackErr = fmt.Errorf("role '%s' was created by someone else during setup; it was not deleted and its changes were not rolled back", snapshot.RoleName)
+		}
+	}
+
+	if len(rolledBack) == 0 && rollbackErr == nil {
+		return setupErr
+	}
+	if rollbackErr != nil {
+		logger.Error().Err(rollbackErr).Strs("rolled_back", rolledBack).Msg("rollback failed")
+	} else {
+		logger.Warn().Strs("rolled_back", rolledBack).Msg("rolled back setup")
+	}
+	return &RollbackError{Err: setupErr, RolledBack: rolledBack, RollbackErr: rollbackErr}
+}
+
+// restoreRole restores the parts of an existing role that differ from the
+// snapshot. It keeps going after a failed step so as much of the role as
+// possible is restored.
+func (c *IAMClient) restoreRole(snapshot *roleSnapshot) ([]string, error) {
+	roleName := snapshot.RoleName
+	current, err := c.snapshotRole(roleName)
+	if err != nil {
+		return nil, err
+	}
+	if !current.Existed {
+		return nil, fmt.Errorf("role '%s' no longer exists", roleName)
+	}
+
+	var rolledBack []string
+	var errs []error
+	step := func(description string, err error) {
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		rolledBack = append(rolledBack, description)
+	}
+
+	switch {
+	case current.InlinePolicy == snapshot.InlinePolicy:
+	case snapshot.InlinePolicy == "":
+		step("removed inline S3 policy", c.deleteInlineS3Policy(roleName))
+	default:
+		step("restored inline S3 policy", c.putInlinePolicy(roleName, s3PolicySpec{Name: S3PolicyName, Document: snapshot.InlinePolicy}))
+	}
+
+	policies, err := c.listSplitPolicies(roleName)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	for _, policy := range policies {
+		name := aws.ToString(policy.PolicyName)
+		if _, ok := snapshot.ManagedPolicies[name]; !ok {
+			step("deleted managed policy "+name, c.deleteSplitPolicy(roleName, aws.ToString(policy.PolicyArn)))
+		}
+	}
+	for _, name := range sortedKeys(snapshot.ManagedPolicies) {
+		document := snapshot.ManagedPolicies[name]
+		if currentDocument, ok := current.ManagedPolicies[name]; ok && currentDocument == document {
+			continue
+		}
+		roleARN, err := c.GetRoleARN(roleName)
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+		spec := s3PolicySpec{Name: name, Managed: true, Document: document}
+		step("restored managed policy "+name, c.putManagedPolicy(roleARN, roleName, spec, snapshot.Tags))
+	}
+
+	if current.TrustPolicy != snapshot.TrustPolicy {
+		step("restored trust policy", c.updateRoleTrustPolicy(roleName, snapshot.TrustPolicy))
+	}
+	if current.Settings != snapshot.Settings {
+		step("restored role settings", c.restoreRoleSettings(roleName, snapshot.Settings))
+	}
+	if !maps.Equal(current.Tags, snapshot.Tags) {
+		step("restored role tags", c.restoreRoleTags(roleName, snapshot.Tags))
+	}
+
+	return rolledBack, errors.Join(errs...)
+}
+
+// restoreRoleSettings sets the description, max session duration and
+// permissions boundary of a role, removing a boundary it did not have before
+func (c *IAMClient) restoreRoleSettings(roleName string, settings RoleSettings) error {
+	_, err := c.Client.UpdateRole(context.TODO(), &iam.UpdateRoleInput{
+		RoleName:           aws.String(roleName),
+		Description:        aws.String(settings.Description),
+		MaxSessionDuration: aws.Int32(settings.MaxSessionDuration),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to restore settings of IAM role '%s': %w", roleName, err)
+	}
+
+	if settings.PermissionsBoundary != "" {
+		_, err = c.Client.PutRolePermissionsBoundary(context.TODO(), &iam.PutRolePermissionsBoundaryInput{
+			RoleName:            aws.String(roleName),
+			PermissionsBoundary: aws.String(settings.PermissionsBoundary),
+		})
+	} else {
+		_, err = c.Client.DeleteRolePermissionsBoundary(context.TODO(), &iam.DeleteRolePermissionsBoundaryInput{
+			RoleName: aws.String(roleName),
+		})
+		var noSuchEntity *types.NoSuchEntityException
+		if errors.As(err, &noSuchEntity) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restore permissions boundary of IAM role '%s': %w", roleName, err)
+	}
+	return nil
+}
+
+// restoreRoleTags sets the tags of a role to exactly tags
+func (c *IAMClient) restoreRoleTags(roleName string, tags map[string]string) error {
+	out, err := c.Client.ListRoleTags(context.TODO(), &iam.ListRoleTagsInput{
+		RoleName: aws.String(roleName),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list tags of IAM role '%s': %w", roleName, err)
+	}
+	var added []string
+	for _, tag := range out.Tags {
+		if _, ok := tags[aws.ToString(tag.Key)]; !ok {
+			added = append(added, aws.ToString(tag.Key))
+		}
+	}
+	if len(added) > 0 {
+		_, err := c.Client.UntagRole(context.TODO(), &iam.UntagRoleInput{
+			RoleName: aws.String(roleName),
+			TagKeys:  added,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to remove tags from IAM role '%s': %w", roleName, err)
+		}
+	}
+	if len(tags) > 0 {
+		_, err := c.Client.TagRole(context.TODO(), &iam.TagRoleInput{
+			RoleName: aws.String(roleName),
+			Tags:     iamTags(tags),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to restore tags of IAM role '%s': %w", roleName, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+// pkg/aws/iam_rotate.go
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TrustPolicyUpdate is a trust policy change computed without applying it
+type TrustPolicyUpdate struct {
+	RoleName string
+	Document string
+}
+
+// PlanExternalIDRotation starts a rotation by adding newID next to the external
+// ID already required by the role's trust policy, so Cribl can switch to newID
+// while jobs using the old ID keep working. Only one rotation can be in
+// progress at a time. The new trust policy is returned without changing the
+// role; apply it with ApplyTrustPolicyUpdate.
+func (c *IAMClient) PlanExternalIDRotation(roleName, newID string) (*TrustPolicyUpdate, error) {
+	logger := c.logger.With().Str("role_name", roleName).Logger()
+
+	if newID == "" {
+		return nil, fmt.Errorf("new external ID cannot be empty")
+	}
+
+	document, err := c.planExternalIDs(roleName, func(current []string) ([]string, error) {
+		if containsString(current, newID) {
+			return nil, fmt.Errorf("external ID is already trusted by role '%s'", roleName)
+		}
+		if len(current) > 1 {
+			return nil, fmt.Errorf("role '%s' already trusts %d external IDs, complete the rotation in progress first", roleName, len(current))
+		}
+		return append(current, newID), nil
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("cannot start external ID rotation")
+		return nil, err
+	}
+	return &TrustPolicyUpdate{RoleName: roleName, Document: document}, nil
+}
+
+// ApplyTrustPolicyUpdate writes a planned trust policy to the role
+func (c *IAMClient) ApplyTrustPolicyUpdate(update *TrustPolicyUpdate) error {
+	if err := c.updateRoleTrustPolicy(update.RoleName, update.Document); err != nil {
+		return err
+	}
+	c.logger.Info().Str("role_name", update.RoleName).Msg("updated trust policy")
+	return nil
+}
+
+// CompleteExternalIDRotation removes every external ID except newID from the
+// role's trust policy
+func (c *IAMClient) CompleteExternalIDRotation(roleName, newID string) error {
+	logger := c.logger.With().Str("role_name", roleName).Logger()
+
+	if newID == "" {
+		return fmt.Errorf("new external ID cannot be empty")
+	}
+
+	err := c.rewriteExternalIDs(roleName, func(current []string) ([]string, error) {
+		if !containsString(current, newID) {
+			return nil, fmt.Errorf("external ID is not trusted by role '%s', start the rotation first", roleName)
+		}
+		return []string{newID}, nil
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to complete external ID rotation")
+		return err
+	}
+
+	logger.Info().Msg("removed old external IDs from trust policy")
+	return nil
+}
+
+// rewriteExternalIDs applies update to the sts:ExternalId values of every
+// statement in the role's trust policy and writes the policy back
+func (c *IAMClient) rewriteExternalIDs(roleName string, update func(current []string) ([]string, error)) error {
+	document, err := c.planExternalIDs(roleName, update)
+	if err != nil {
+		return err
+	}
+	return c.updateRoleTrustPolicy(roleName, document)
+}
+
+// planExternalIDs applies update to the sts:ExternalId values of every
+// statement in the role's trust policy and returns the new document. The
+// document is edited generically so statements and conditions this tool does
+// not model are preserved.
+func (c *IAMClient) planExternalIDs(roleName string, update func(current []string) ([]string, error)) (string, error) {
+	document, err := c.getTrustPolicy(roleName)
+	if err != nil {
+		return "", err
+	}
+	if document == "" {
+		return "", fmt.Errorf("role '%s' does not exist", roleName)
+	}
+
+	var policy map[string]interface{}
+	if err := json.Unmarshal([]byte(document), &policy); err != nil {
+		return "", fmt.Errorf("failed to parse trust policy of role '%s': %w", roleName, err)
+	}
+
+	statements, ok := policy["Statement"].([]interface{})
+	if !ok {
+		statements = []interface{}{policy["Statement"]}
+	}
+
+	updated := 0
+	for _, raw := range statements {
+		statement, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		condition, ok := statement["Condition"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		stringEquals, ok := condition["StringEquals"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for key, value := range stringEquals {
+			if !strings.EqualFold(key, "sts:ExternalId") {
+				continue
+			}
+			ids, err := update(policyStrings(value))
+			if err != nil {
+				return "", err
+			}
+			stringEquals[key] = StringList(ids)
+			updated++
+		}
+	}
+	if updated == 0 {
+		return "", fmt.Errorf("trust policy of role '%s' has no sts:ExternalId condition to rotate", roleName)
+	}
+
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal trust policy: %w", err)
+	}
+	return string(policyJSON), nil
+}
+
+// policyStrings returns a policy value that is either a string or a list of
+// strings as a slice
+func policyStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,616 @@
+// pkg/aws/iam_split.go
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// IAM policy size limits, counted without whitespace
+const (
+	inlinePolicySizeLimit  = 10240
+	managedPolicySizeLimit = 6144
+
+	// maxPolicyVersions is the number of versions IAM keeps per managed policy
+	maxPolicyVersions = 5
+
+	// defaultAttachedPoliciesPerRole is the default quota of managed policies
+	// attached to a role
+	defaultAttachedPoliciesPerRole = 10
+)
+
+// policyBudget is the room left on a role for the S3 policies of this tool
+type policyBudget struct {
+	// InlineSize is the size left for the inline S3 policy, since the inline
+	// size limit applies to all inline policies of a role together
+	InlineSize int
+	// ManagedPolicies is the number of managed policies that can be attached
+	ManagedPolicies int
+}
+
+// s3PolicySpec is one policy document holding part of the bucket grants
+type s3PolicySpec struct {
+	Name     string
+	Managed  bool
+	Document string
+}
+
+// splitPolicyName returns the name of the i-th customer-managed S3 policy of a
+// role. Managed policy names are unique per account, so they include the role name.
+func splitPolicyName(roleName string, i int) string {
+	return fmt.Sprintf("%s-%s-%d", roleName, S3PolicyName, i)
+}
+
+// splitPolicyIndex returns the index of a managed S3 policy created for roleName
+func splitPolicyIndex(roleName, policyName string) (int, bool) {
+	suffix, ok := strings.CutPrefix(policyName, roleName+"-"+S3PolicyName+"-")
+	if !ok {
+		return 0, false
+	}
+	i, err := strconv.Atoi(suffix)
+	if err != nil || i < 1 {
+		return 0, false
+	}
+	return i, true
+}
+
+// buildS3Policies returns the policies needed to grant access to all buckets.
+// A single inline policy is used while it fits the budget, otherwise the grants
+// are packed into numbered customer-managed policies, as many as the budget allows. With
+// compact set and grants that do not fit, buckets sharing a naming pattern are
+// collapsed into wildcard grants, largest group first, until they fit.
+func (c *IAMClient) buildS3Policies(roleName, action string, grants []BucketGrant, kmsKeyARNs []string, compact bool, budget policyBudget) ([]s3PolicySpec, error) {
+	logger := c.logger.With().
+		Str("role_name", roleName).
+		Int("grant_count", len(grants)).
+		Logger()
+
+	specs, err := c.fitS3Policies(roleName, action, grants, kmsKeyARNs, budget)
+	if err != nil && compact {
+		var compactErr error
+		compacted, ok := compactGrants(grants, func(candidate []BucketGrant) bool {
+			specs, compactErr = c.fitS3Policies(roleName, action, candidate, kmsKeyARNs, budget)
+			return compactErr == nil
+		})
+		if !ok {
+			if compactErr == nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w, even with buckets compacted into wildcards", compactErr)
+		}
+		for _, grant := range compacted {
+			if strings.Contains(grant.Bucket, "*") {
+				logger.Warn().Str("pattern", grant.Bucket).Msg("compacted buckets into wildcard grant, future buckets matching the pattern are also granted")
+			}
+		}
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(specs) > 1 || specs[0].Managed {
+		logger.Info().Int("policy_count", len(specs)).Msg("split S3 grants into managed policies")
+	}
+	return specs, nil
+}
+
+// fitS3Policies returns a single inline policy for the grants if it fits, or
+// the managed policies they are split into. It fails if that takes more
+// managed policies than the budget allows.
+func (c *IAMClient) fitS3Policies(roleName, action string, grants []BucketGrant, kmsKeyARNs []string, budget policyBudget) ([]s3PolicySpec, error) {
+	document := c.createS3PolicyDocument(action, grants, kmsKeyARNs)
+	if len(document) <= budget.InlineSize {
+		return []s3PolicySpec{{Name: S3PolicyName, Document: document}}, nil
+	}
+
+	var specs []s3PolicySpec
+	var chunk []BucketGrant
+	var chunkDocument string
+	for _, grant := range grants {
+		// KMS keys are granted once, in the first policy
+		keys := kmsKeyARNs
+		if len(specs) > 0 {
+			keys = nil
+		}
+
+		candidate := c.createS3PolicyDocument(action, append(chunk, grant), keys)
+		if len(candidate) <= managedPolicySizeLimit {
+			chunk = append(chunk, grant)
+			chunkDocument = candidate
+			continue
+		}
+		if len(chunk) == 0 {
+			return nil, fmt.Errorf("policy for bucket '%s' exceeds the managed policy size limit", grant.String())
+		}
+
+		specs = append(specs, s3PolicySpec{
+			Name:     splitPolicyName(roleName, len(specs)+1),
+			Managed:  true,
+			Document: chunkDocument,
+		})
+		chunk = []BucketGrant{grant}
+		chunkDocument = c.createS3PolicyDocument(action, chunk, nil)
+		if len(chunkDocument) > managedPolicySizeLimit {
+			return nil, fmt.Errorf("policy for bucket '%s' exceeds the managed policy size limit", grant.String())
+		}
+	}
+	if len(chunk) > 0 {
+		specs = append(specs, s3PolicySpec{
+			Name:     splitPolicyName(roleName, len(specs)+1),
+			Managed:  true,
+			Document: chunkDocument,
+		})
+	}
+
+	if len(specs) > budget.ManagedPolicies {
+		return nil, fmt.Errorf("the bucket grants need %d managed policies but role '%s' can only have %d more attached; grant fewer buckets or use --compact-wildcards",
+			len(specs), roleName, budget.ManagedPolicies)
+	}
+	return specs, nil
+}
+
+// compactGrants collapses whole-bucket grants that only differ in their last
+// "-" or "." separated segment into one wildcard grant, e.g. logs-us-east-1 and
+// logs-us-west-2 become logs-us-*. Groups are collapsed one at a time, largest
+// first, until fits accepts the grants; it reports false if they never fit.
+// Prefix grants are left untouched.
+func compactGrants(grants []BucketGrant, fits func([]BucketGrant) bool) ([]BucketGrant, bool) {
+	groups := make(map[string]int)
+	var stems []string
+	for _, grant := range grants {
+		if stem, ok := grantStem(grant); ok {
+			if groups[stem] == 0 {
+				stems = append(stems, stem)
+			}
+			groups[stem]++
+		}
+	}
+
+	var candidates []string
+	for _, stem := range stems {
+		if groups[stem] >= 2 {
+			candidates = append(candidates, stem)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if groups[candidates[i]] != groups[candidates[j]] {
+			return groups[candidates[i]] > groups[candidates[j]]
+		}
+		return candidates[i] < candidates[j]
+	})
+
+	compacted := make(map[string]bool)
+	var result []BucketGrant
+	for _, stem := range candidates {
+		compacted[stem] = true
+
+		result = result[:0:0]
+		added := make(map[string]bool)
+		for _, grant := range grants {
+			stem, ok := grantStem(grant)
+			switch {
+			case !ok || !compacted[stem]:
+				result = append(result, grant)
+			case !added[stem]:
+				added[stem] = true
+				result = append(result, BucketGrant{Bucket: stem + "*"})
+			}
+		}
+		if fits(result) {
+			return result, true
+		}
+	}
+	return result, false
+}
+
+// grantStem returns the bucket name up to its last "-" or "." separator for
+// whole-bucket grants that can be compacted
+func grantStem(grant BucketGrant) (string, bool) {
+	i := strings.LastIndexAny(grant.Bucket, "-.")
+	if grant.Prefix != "" || i < 3 {
+		return "", false
+	}
+	return grant.Bucket[:i+1], true
+}
+
+// availablePolicyBudget returns the room left on the role for the S3 policies:
+// the inline size limit minus the size of the role's other inline policies, and
+// the account's per-role quota of managed policies, 10 unless raised, minus the
+// policies attached by others. The S3 policies of this tool are replaced, so
+// they do not count.
+func (c *IAMClient) availablePolicyBudget(roleName string) (policyBudget, error) {
+	quota := defaultAttachedPoliciesPerRole
+	summary, err := c.Client.GetAccountSummary(context.TODO(), &iam.GetAccountSummaryInput{})
+	if err != nil {
+		c.logger.Warn().Err(err).Int("quota", quota).Msg("unable to read the attached policies per role quota, assuming the default")
+	} else if value, ok := summary.SummaryMap[string(types.SummaryKeyTypeAttachedPoliciesPerRoleQuota)]; ok {
+		quota = int(value)
+	}
+	budget := policyBudget{InlineSize: inlinePolicySizeLimit, ManagedPolicies: quota}
+
+	used := 0
+	paginator := iam.NewListAttachedRolePoliciesPaginator(c.Client, &iam.ListAttachedRolePoliciesInput{
+		RoleName: aws.String(roleName),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			var noSuchEntity *types.NoSuchEntityException
+			if errors.As(err, &noSuchEntity) {
+				return budget, nil
+			}
+			return policyBudget{}, fmt.Errorf("failed to list attached policies for role '%s': %w", roleName, err)
+		}
+		for _, policy := range page.AttachedPolicies {
+			if _, ok := splitPolicyIndex(roleName, aws.ToString(policy.PolicyName)); !ok {
+				used++
+			}
+		}
+	}
+	budget.ManagedPolicies = max(quota-used, 0)
+
+	inlineSize, err := c.otherInlinePolicySize(roleName)
+	if err != nil {
+		return policyBudget{}, err
+	}
+	budget.InlineSize = max(inlinePolicySizeLimit-inlineSize, 0)
+	return budget, nil
+}
+
+// otherInlinePolicySize returns the combined size, without whitespace, of the
+// role's inline policies other than the S3 policy of this tool
+func (c *IAMClient) otherInlinePolicySize(roleName string) (int, error) {
+	size := 0
+	paginator := iam.NewListRolePoliciesPaginator(c.Client, &iam.ListRolePoliciesInput{
+		RoleName: aws.String(roleName),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			var noSuchEntity *types.NoSuchEntityException
+			if errors.As(err, &noSuchEntity) {
+				return 0, nil
+			}
+			return 0, fmt.Errorf("failed to list inline policies for role '%s': %w", roleName, err)
+		}
+		for _, policyName := range page.PolicyNames {
+			if policyName == S3PolicyName {
+				continue
+			}
+			document, err := c.getRolePolicy(roleName, policyName)
+			if err != nil {
+				return 0, err
+			}
+			var compacted bytes.Buffer
+			if err := json.Compact(&compacted, []byte(document)); err != nil {
+				size += len(document)
+				continue
+			}
+			size += compacted.Len()
+		}
+	}
+	return size, nil
+}
+
+// putS3Policies writes the policies built by buildS3Policies and removes any
+// policies left over from an earlier run that used a different layout
+func (c *IAMClient) putS3Policies(roleName string, specs []s3PolicySpec, tags map[string]string) error {
+	if len(specs) == 1 && !specs[0].Managed {
+		if err := c.putInlinePolicy(roleName, specs[0]); err != nil {
+			return err
+		}
+		return c.removeStaleSplitPolicies(roleName, 0)
+	}
+
+	roleARN, err := c.GetRoleARN(roleName)
+	if err != nil {
+		return err
+	}
+	for _, spec := range specs {
+		if err := c.putManagedPolicy(roleARN, roleName, spec, tags); err != nil {
+			return err
+		}
+	}
+
+	// The grants now live in managed policies, so drop the inline policy
+	if err := c.deleteInlineS3Policy(roleName); err != nil {
+		return err
+	}
+
+	return c.removeStaleSplitPolicies(roleName, len(specs))
+}
+
+func (c *IAMClient) putInlinePolicy(roleName string, spec s3PolicySpec) error {
+	logger := c.logger.With().
+		Str("role_name", roleName).
+		Str("policy_name", spec.Name).
+		Logger()
+
+	logger.Debug().RawJSON("policy_document", []byte(spec.Document)).Msg("creating S3 policy")
+
+	_, err := c.Client.PutRolePolicy(context.TODO(), &iam.PutRolePolicyInput{
+		RoleName:       aws.String(roleName),
+		PolicyName:     aws.String(spec.Name),
+		PolicyDocument: aws.String(spec.Document),
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to attach policy to role")
+		return fmt.Errorf("failed to attach policy to role '%s': %w", roleName, err)
+	}
+
+	logger.Info().Msg("attached policy to IAM role")
+	return nil
+}
+
+// deleteInlineS3Policy deletes the inline S3 policy of the role if it exists
+func (c *IAMClient) deleteInlineS3Policy(roleName string) error {
+	_, err := c.Client.DeleteRolePolicy(context.TODO(), &iam.DeleteRolePolicyInput{
+		RoleName:   aws.String(roleName),
+		PolicyName: aws.String(S3PolicyName),
+	})
+	var noSuchEntity *types.NoSuchEntityException
+	if err != nil && !errors.As(err, &noSuchEntity) {
+		c.logger.Error().Err(err).Str("role_name", roleName).Msg("failed to delete inline policy")
+		return fmt.Errorf("failed to delete inline policy '%s': %w", S3PolicyName, err)
+	}
+	return nil
+}
+
+// putManagedPolicy creates the managed policy or adds a new default version
+// and updates its tags, then attaches it to the role
+func (c *IAMClient) putManagedPolicy(roleARN, roleName string, spec s3PolicySpec, tags map[string]string) error {
+	policyARN := managedPolicyARN(roleARN, spec.Name)
+	logger := c.logger.With().
+		Str("role_name", roleName).
+		Str("policy_arn", policyARN).
+		Logger()
+
+	_, err := c.Client.GetPolicy(context.TODO(), &iam.GetPolicyInput{
+		PolicyArn: aws.String(policyARN),
+	})
+	var noSuchEntity *types.NoSuchEntityException
+	switch {
+	case errors.As(err, &noSuchEntity):
+		_, err = c.Client.CreatePolicy(context.TODO(), &iam.CreatePolicyInput{
+			PolicyName:     aws.String(spec.Name),
+			PolicyDocument: aws.String(spec.Document),
+			Description:    aws.String(fmt.Sprintf("S3 access for role %s", roleName)),
+			Tags:           iamTags(tags),
+		})
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to create managed policy")
+			return fmt.Errorf("failed to create managed policy '%s': %w", spec.Name, err)
+		}
+		logger.Info().Msg("created managed policy")
+	case err != nil:
+		logger.Error().Err(err).Msg("failed to get managed policy")
+		return fmt.Errorf("failed to get managed policy '%s': %w", spec.Name, err)
+	default:
+		if err := c.pruneOldPolicyVersions(policyARN, maxPolicyVersions-1); err != nil {
+			return err
+		}
+		_, err = c.Client.CreatePolicyVersion(context.TODO(), &iam.CreatePolicyVersionInput{
+			PolicyArn:      aws.String(policyARN),
+			PolicyDocument: aws.String(spec.Document),
+			SetAsDefault:   true,
+		})
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to update managed policy")
+			return fmt.Errorf("failed to update managed policy '%s': %w", spec.Name, err)
+		}
+		if err := c.tagManagedPolicy(policyARN, tags); err != nil {
+			return err
+		}
+		logger.Info().Msg("updated managed policy")
+	}
+
+	_, err = c.Client.AttachRolePolicy(context.TODO(), &iam.AttachRolePolicyInput{
+		RoleName:  aws.String(roleName),
+		PolicyArn: aws.String(policyARN),
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to attach managed policy")
+		return fmt.Errorf("failed to attach managed policy '%s' to role '%s': %w", spec.Name, roleName, err)
+	}
+	return nil
+}
+
+// pruneOldPolicyVersions deletes the oldest non-default versions of a managed
+// policy until at most keep versions remain
+func (c *IAMClient) pruneOldPolicyVersions(policyARN string, keep int) error {
+	out, err := c.Client.ListPolicyVersions(context.TODO(), &iam.ListPolicyVersionsInput{
+		PolicyArn: aws.String(policyARN),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list versions of policy '%s': %w", policyARN, err)
+	}
+
+	versions := out.Versions
+	sort.Slice(versions, func(i, j int) bool {
+		return aws.ToTime(versions[i].CreateDate).Before(aws.ToTime(versions[j].CreateDate))
+	})
+	remaining := len(versions)
+	for _, version := range versions {
+		if remaining <= keep {
+			break
+		}
+		if version.IsDefaultVersion {
+			continue
+		}
+		_, err := c.Client.DeletePolicyVersion(context.TODO(), &iam.DeletePolicyVersionInput{
+			PolicyArn: aws.String(policyARN),
+			VersionId: version.VersionId,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete version %s of policy '%s': %w", aws.ToString(version.VersionId), policyARN, err)
+		}
+		remaining--
+	}
+	return nil
+}
+
+// removeStaleSplitPolicies detaches and deletes the managed S3 policies of the
+// role numbered above keep
+func (c *IAMClient) removeStaleSplitPolicies(roleName string, keep int) error {
+	policies, err := c.listSplitPolicies(roleName)
+	if err != nil {
+		return err
+	}
+	for _, policy := range policies {
+		i, _ := splitPolicyIndex(roleName, aws.ToString(policy.PolicyName))
+		if i <= keep {
+			continue
+		}
+		if err := c.deleteSplitPolicy(roleName, aws.ToString(policy.PolicyArn)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteSplitPolicy detaches a managed S3 policy from the role and deletes it
+// along with all of its versions
+func (c *IAMClient) deleteSplitPolicy(roleName, policyARN string) error {
+	logger := c.logger.With().
+		Str("role_name", roleName).
+		Str("policy_arn", policyARN).
+		Logger()
+
+	_, err := c.Client.DetachRolePolicy(context.TODO(), &iam.DetachRolePolicyInput{
+		RoleName:  aws.String(roleName),
+		PolicyArn: aws.String(policyARN),
+	})
+	var noSuchEntity *types.NoSuchEntityException
+	if err != nil && !errors.As(err, &noSuchEntity) {
+		logger.Error().Err(err).Msg("failed to detach managed policy")
+		return fmt.Errorf("failed to detach managed policy '%s': %w", policyARN, err)
+	}
+
+	if err := c.pruneOldPolicyVersions(policyARN, 1); err != nil {
+		return err
+	}
+	_, err = c.Client.DeletePolicy(context.TODO(), &iam.DeletePolicyInput{
+		PolicyArn: aws.String(policyARN),
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to delete managed policy")
+		return fmt.Errorf("failed to delete managed policy '%s': %w", policyARN, err)
+	}
+
+	logger.Info().Msg("deleted stale managed policy")
+	return nil
+}
+
+// listSplitPolicies returns the managed S3 policies attached to the role, in order
+func (c *IAMClient) listSplitPolicies(roleName string) ([]types.AttachedPolicy, error) {
+	var policies []types.AttachedPolicy
+
+	paginator := iam.NewListAttachedRolePoliciesPaginator(c.Client, &iam.ListAttachedRolePoliciesInput{
+		RoleName: aws.String(roleName),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			var noSuchEntity *types.NoSuchEntityException
+			if errors.As(err, &noSuchEntity) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("failed to list attached policies for role '%s': %w", roleName, err)
+		}
+		for _, policy := range page.AttachedPolicies {
+			if _, ok := splitPolicyIndex(roleName, aws.ToString(policy.PolicyName)); ok {
+				policies = append(policies, policy)
+			}
+		}
+	}
+
+	sort.Slice(policies, func(i, j int) bool {
+		a, _ := splitPolicyIndex(roleName, aws.ToString(policies[i].PolicyName))
+		b, _ := splitPolicyIndex(roleName, aws.ToString(policies[j].PolicyName))
+		return a < b
+	})
+	return policies, nil
+}
+
+// getManagedPolicyDocument returns the decoded default version of a managed
+// policy, or an empty string if the policy does not exist
+func (c *IAMClient) getManagedPolicyDocument(policyARN string) (string, error) {
+	policy, err := c.Client.GetPolicy(context.TODO(), &iam.GetPolicyInput{
+		PolicyArn: aws.String(policyARN),
+	})
+	if err != nil {
+		var noSuchEntity *types.NoSuchEntityException
+		if errors.As(err, &noSuchEntity) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get managed policy '%s': %w", policyARN, err)
+	}
+
+	version, err := c.Client.GetPolicyVersion(context.TODO(), &iam.GetPolicyVersionInput{
+		PolicyArn: aws.String(policyARN),
+		VersionId: policy.Policy.DefaultVersionId,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get default version of policy '%s': %w", policyARN, err)
+	}
+	return decodePolicyDocument(aws.ToString(version.PolicyVersion.Document))
+}
+
+// getS3Policy returns the role's S3 grants as one document, merging the inline
+// policy and any numbered managed policies. It is empty if neither exists.
+func (c *IAMClient) getS3Policy(roleName string) (RolePolicyDocument, error) {
+	var merged RolePolicyDocument
+
+	documents := []string{}
+	inline, err := c.getRolePolicy(roleName, S3PolicyName)
+	if err != nil {
+		return merged, err
+	}
+	if inline != "" {
+		documents = append(documents, inline)
+	}
+
+	policies, err := c.listSplitPolicies(roleName)
+	if err != nil {
+		return merged, err
+	}
+	for _, policy := range policies {
+		document, err := c.getManagedPolicyDocument(aws.ToString(policy.PolicyArn))
+		if err != nil {
+			return merged, err
+		}
+		if document != "" {
+			documents = append(documents, document)
+		}
+	}
+
+	for _, document := range documents {
+		var policy RolePolicyDocument
+		if err := json.Unmarshal([]byte(document), &policy); err != nil {
+			return merged, fmt.Errorf("failed to parse S3 policy of role '%s': %w", roleName, err)
+		}
+		merged.Version = policy.Version
+		merged.Statement = append(merged.Statement, policy.Statement...)
+	}
+	return merged, nil
+}
+
+// managedPolicyARN returns the ARN of a customer-managed policy in the role's account
+func managedPolicyARN(roleARN, policyName string) string {
+	parts := strings.SplitN(roleARN, ":", 6)
+	if len(parts) != 6 {
+		return ""
+	}
+	return fmt.Sprintf("arn:%s:iam::%s:policy/%s", parts[1], parts[4], policyName)
+}
@@ -0,0 +1,176 @@
+// pkg/aws/iam_teardown.go
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// TeardownRole removes a role created by SetupTrustRelationship along with its
+// inline policies, managed policy attachments and instance profile memberships.
+// Managed S3 policies created for the role are deleted as well.
+// Roles that were not created by this tool are refused unless force is set.
+func (c *IAMClient) TeardownRole(roleName string, force bool) error {
+	logger := c.logger.With().
+		Str("role_name", roleName).
+		Bool("force", force).
+		Logger()
+
+	if roleName == "" {
+		return fmt.Errorf("roleName cannot be empty")
+	}
+
+	out, err := c.Client.GetRole(context.TODO(), &iam.GetRoleInput{
+		RoleName: aws.String(roleName),
+	})
+	if err != nil {
+		var noSuchEntity *types.NoSuchEntityException
+		if errors.As(err, &noSuchEntity) {
+			logger.Error().Msg("role does not exist")
+			return fmt.Errorf("role '%s' does not exist", roleName)
+		}
+		logger.Error().Err(err).Msg("failed to get IAM role")
+		return fmt.Errorf("failed to get IAM role: %w", err)
+	}
+
+	if !isManagedRole(out.Role) {
+		if !force {
+			logger.Error().Msg("role is not managed by cribl-storage-tool")
+			return fmt.Errorf("role '%s' was not created by cribl-storage-tool; use --force to delete it anyway", roleName)
+		}
+		logger.Warn().Msg("role is not managed by cribl-storage-tool, deleting because force is set")
+	}
+
+	if err := c.deleteInlinePolicies(roleName); err != nil {
+		return err
+	}
+
+	if err := c.detachManagedPolicies(roleName); err != nil {
+		return err
+	}
+
+	if err := c.removeInstanceProfiles(roleName); err != nil {
+		return err
+	}
+
+	_, err = c.Client.DeleteRole(context.TODO(), &iam.DeleteRoleInput{
+		RoleName: aws.String(roleName),
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to delete IAM role")
+		return fmt.Errorf("failed to delete IAM role '%s': %w", roleName, err)
+	}
+
+	logger.Info().Msg("deleted IAM role")
+	return nil
+}
+
+// isManagedRole reports whether a role carries the managed-by tag. Roles created
+// before tagging was introduced are adopted with iam import; the description
+// alone is not trusted since anyone can copy it.
+func isManagedRole(role *types.Role) bool {
+	if role == nil {
+		return false
+	}
+	for _, tag := range role.Tags {
+		if aws.ToString(tag.Key) == ManagedByTagKey && aws.ToString(tag.Value) == ManagedByTagValue {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *IAMClient) deleteInlinePolicies(roleName string) error {
+	logger := c.logger.With().Str("role_name", roleName).Logger()
+
+	paginator := iam.NewListRolePoliciesPaginator(c.Client, &iam.ListRolePoliciesInput{
+		RoleName: aws.String(roleName),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to list inline policies")
+			return fmt.Errorf("failed to list inline policies for role '%s': %w", roleName, err)
+		}
+		for _, policyName := range page.PolicyNames {
+			_, err := c.Client.DeleteRolePolicy(context.TODO(), &iam.DeleteRolePolicyInput{
+				RoleName:   aws.String(roleName),
+				PolicyName: aws.String(policyName),
+			})
+			if err != nil {
+				logger.Error().Err(err).Str("policy_name", policyName).Msg("failed to delete inline policy")
+				return fmt.Errorf("failed to delete inline policy '%s': %w", policyName, err)
+			}
+			logger.Info().Str("policy_name", policyName).Msg("deleted inline policy")
+		}
+	}
+	return nil
+}
+
+func (c *IAMClient) detachManagedPolicies(roleName string) error {
+	logger := c.logger.With().Str("role_name", roleName).Logger()
+
+	paginator := iam.NewListAttachedRolePoliciesPaginator(c.Client, &iam.ListAttachedRolePoliciesInput{
+		RoleName: aws.String(roleName),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to list attached policies")
+			return fmt.Errorf("failed to list attached policies for role '%s': %w", roleName, err)
+		}
+		for _, policy := range page.AttachedPolicies {
+			// Managed S3 policies created by setup are deleted, not just detached
+			if _, ok := splitPolicyIndex(roleName, aws.ToString(policy.PolicyName)); ok {
+				if err := c.deleteSplitPolicy(roleName, aws.ToString(policy.PolicyArn)); err != nil {
+					return err
+				}
+				continue
+			}
+			_, err := c.Client.DetachRolePolicy(context.TODO(), &iam.DetachRolePolicyInput{
+				RoleName:  aws.String(roleName),
+				PolicyArn: policy.PolicyArn,
+			})
+			if err != nil {
+				logger.Error().Err(err).Str("policy_arn", aws.ToString(policy.PolicyArn)).Msg("failed to detach managed policy")
+				return fmt.Errorf("failed to detach managed policy '%s': %w", aws.ToString(policy.PolicyArn), err)
+			}
+			logger.Info().Str("policy_arn", aws.ToString(policy.PolicyArn)).Msg("detached managed policy")
+		}
+	}
+	return nil
+}
+
+// removeInstanceProfiles removes the role from any instance profiles. The
+// profiles themselves are left in place since they may be shared.
+func (c *IAMClient) removeInstanceProfiles(roleName string) error {
+	logger := c.logger.With().Str("role_name", roleName).Logger()
+
+	paginator := iam.NewListInstanceProfilesForRolePaginator(c.Client, &iam.ListInstanceProfilesForRoleInput{
+		RoleName: aws.String(roleName),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to list instance profiles")
+			return fmt.Errorf("failed to list instance profiles for role '%s': %w", roleName, err)
+		}
+		for _, profile := range page.InstanceProfiles {
+			_, err := c.Client.RemoveRoleFromInstanceProfile(context.TODO(), &iam.RemoveRoleFromInstanceProfileInput{
+				RoleName:            aws.String(roleName),
+				InstanceProfileName: profile.InstanceProfileName,
+			})
+			if err != nil {
+				logger.Error().Err(err).Str("instance_profile", aws.ToString(profile.InstanceProfileName)).Msg("failed to remove role from instance profile")
+				return fmt.Errorf("failed to remove role from instance profile '%s': %w", aws.ToString(profile.InstanceProfileName), err)
+			}
+			logger.Info().Str("instance_profile", aws.ToString(profile.InstanceProfileName)).Msg("removed role from instance profile")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+// pkg/aws/iam_verify.go
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// verifyObjectKey is the object name used when simulating object-level actions
+const verifyObjectKey = "cribl-storage-tool-verify"
+
+// VerifyReport is the outcome of simulating a role's access to its buckets
+type VerifyReport struct {
+	RoleName string         `json:"role_name"`
+	RoleARN  string         `json:"role_arn"`
+	Action   string         `json:"action"`
+	Results  []VerifyResult `json:"results"`
+}
+
+// VerifyResult is the simulated decision for one bucket and S3 or KMS action
+type VerifyResult struct {
+	Bucket   string `json:"bucket"`
+	Action   string `json:"action"`
+	Resource string `json:"resource"`
+	Decision string `json:"decision"`
+	Allowed  bool   `json:"allowed"`
+}
+
+// Passed reports whether every simulated action was allowed
+func (r *VerifyReport) Passed() bool {
+	for _, result := range r.Results {
+		if !result.Allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// VerifyRole simulates the role's identity policies for every bucket and
+// action that the Cribl action requires, and for the KMS keys if given.
+func (c *IAMClient) VerifyRole(roleName, action string, grants []BucketGrant, kmsKeyARNs []string) (*VerifyReport, error) {
+	logger := c.logger.With().
+		Str("role_name", roleName).
+		Str("action", action).
+		Logger()
+
+	if err := ValidateAction(action); err != nil {
+		return nil, err
+	}
+
+	roleARN, err := c.GetRoleARN(roleName)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VerifyReport{
+		RoleName: roleName,
+		RoleARN:  roleARN,
+		Action:   action,
+	}
+	permissions := actionPermissions[action]
+	partition := c.Partition()
+
+	for _, grant := range grants {
+		var contextEntries []types.ContextEntry
+		if grant.Prefix != "" {
+			contextEntries = append(contextEntries, stringContextEntry("s3:prefix", grant.Prefix))
+		}
+
+		results, err := c.simulate(roleARN, grant.String(), permissions.Bucket, grant.BucketARN(partition), contextEntries)
+		if err != nil {
+			return nil, err
+		}
+		report.Results = append(report.Results, results...)
+
+		objectARN := fmt.Sprintf("arn:%s:s3:::%s/%s%s", partition, grant.Bucket, grant.Prefix, verifyObjectKey)
+		results, err = c.simulate(roleARN, grant.String(), permissions.Object, objectARN, nil)
+		if err != nil {
+			return nil, err
+		}
+		report.Results = append(report.Results, results...)
+	}
+
+	for _, keyARN := range kmsKeyARNs {
+		key, err := ParseKMSKeyARN(keyARN)
+		if err != nil {
+			return nil, err
+		}
+		contextEntries := []types.ContextEntry{
+			stringContextEntry("kms:ViaService", fmt.Sprintf("s3.%s.%s", key.Region, dnsSuffix(key.Partition))),
+		}
+		results, err := c.simulate(roleARN, keyARN, permissions.KMS, keyARN, contextEntries)
+		if err != nil {
+			return nil, err
+		}
+		report.Results = append(report.Results, results...)
+	}
+
+	logger.Info().Bool("passed", report.Passed()).Msg("verified role access")
+	return report, nil
+}
+
+func (c *IAMClient) simulate(roleARN, bucket string, actions []string, resourceARN string, contextEntries []types.ContextEntry) ([]VerifyResult, error) {
+	var results []VerifyResult
+
+	paginator := iam.NewSimulatePrincipalPolicyPaginator(c.Client, &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: aws.String(roleARN),
+		ActionNames:     actions,
+		ResourceArns:    []string{resourceARN},
+		ContextEntries:  contextEntries,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			c.logger.Error().Err(err).Str("role_arn", roleARN).Msg("failed to simulate principal policy")
+			return nil, fmt.Errorf("failed to simulate policy for role '%s': %w", roleARN, err)
+		}
+		for _, eval := range page.EvaluationResults {
+			results = append(results, VerifyResult{
+				Bucket:   bucket,
+				Action:   aws.ToString(eval.EvalActionName),
+				Resource: resourceARN,
+				Decision: string(eval.EvalDecision),
+				Allowed:  eval.EvalDecision == types.PolicyEvaluationDecisionTypeAllowed,
+			})
+		}
+	}
+	return results, nil
+}
+
+func stringContextEntry(key, value string) types.ContextEntry {
+	return types.ContextEntry{
+		ContextKeyName:   aws.String(key),
+		ContextKeyType:   types.ContextKeyTypeEnumString,
+		ContextKeyValues: []string{value},
+	}
+}
+
+// PrintText prints a pass/fail matrix with one row per bucket and one column per action
+func (r *VerifyReport) PrintText() {
+	fmt.Printf("Verification for role %q (action: %s):\n", r.RoleName, r.Action)
+
+	var buckets, actions []string
+	decisions := make(map[string]map[string]VerifyResult)
+	for _, result := range r.Results {
+		if _, ok := decisions[result.Bucket]; !ok {
+			decisions[result.Bucket] = make(map[string]VerifyResult)
+			buckets = append(buckets, result.Bucket)
+		}
+		decisions[result.Bucket][result.Action] = result
+		if !containsString(actions, result.Action) {
+			actions = append(actions, result.Action)
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "BUCKET\t%s\n", strings.Join(actions, "\t"))
+	failed := 0
+	for _, bucket := range buckets {
+		cells := make([]string, 0, len(actions))
+		bucketPassed := true
+		for _, action := range actions {
+			result, ok := decisions[bucket][action]
+			switch {
+			case !ok:
+				cells = append(cells, "-")
+			case result.Allowed:
+				cells = append(cells, "PASS")
+			default:
+				cells = append(cells, "FAIL ("+result.Decision+")")
+				bucketPassed = false
+			}
+		}
+		if !bucketPassed {
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\n", bucket, strings.Join(cells, "\t"))
+	}
+	w.Flush()
+
+	fmt.Printf("Result: %d of %d passed\n", len(buckets)-failed, len(buckets))
+}
+
+// PrintJSON prints the report in JSON format
+func (r *VerifyReport) PrintJSON() error {
+	jsonData, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(jsonData))
+	return nil
+}
@@ -0,0 +1,138 @@
+// pkg/aws/kms.go
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// KMSKey is a parsed KMS key ARN
+type KMSKey struct {
+	ARN       string
+	Partition string
+	Region    string
+	AccountID string
+	KeyID     string
+}
+
+// ParseKMSKeyARN parses a key ARN of the form arn:PARTITION:kms:REGION:ACCOUNT:key/KEY_ID.
+// Aliases and bare key IDs are rejected since key grants in IAM policies must use key ARNs.
+func ParseKMSKeyARN(arn string) (KMSKey, error) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "kms" {
+		return KMSKey{}, fmt.Errorf("invalid KMS key ARN '%s', expected arn:PARTITION:kms:REGION:ACCOUNT:key/KEY_ID", arn)
+	}
+	if parts[3] == "" || parts[4] == "" {
+		return KMSKey{}, fmt.Errorf("invalid KMS key ARN '%s', region and account ID are required", arn)
+	}
+	if err := ValidatePartition(parts[1]); err != nil {
+		return KMSKey{}, fmt.Errorf("invalid KMS key ARN '%s': %w", arn, err)
+	}
+	if err := ValidateAccountID(parts[4]); err != nil {
+		return KMSKey{}, fmt.Errorf("invalid KMS key ARN '%s': %w", arn, err)
+	}
+	if strings.HasPrefix(parts[5], "alias/") {
+		return KMSKey{}, fmt.Errorf("KMS alias '%s' cannot be used in an IAM policy, pass the key ARN instead", arn)
+	}
+	keyID, ok := strings.CutPrefix(parts[5], "key/")
+	if !ok || keyID == "" {
+		return KMSKey{}, fmt.Errorf("invalid KMS key ARN '%s', expected a key/KEY_ID resource", arn)
+	}
+
+	return KMSKey{
+		ARN:       arn,
+		Partition: parts[1],
+		Region:    parts[3],
+		AccountID: parts[4],
+		KeyID:     keyID,
+	}, nil
+}
+
+// kmsKeysFromPolicy returns the KMS key ARNs granted by a policy
+func kmsKeysFromPolicy(doc RolePolicyDocument) []string {
+	var keyARNs []string
+	for _, statement := range doc.Statement {
+		if statement.Effect != "Allow" {
+			continue
+		}
+		for _, resource := range statement.Resource {
+			if _, err := ParseKMSKeyARN(resource); err == nil {
+				keyARNs = appendUniqueStrings(keyARNs, resource)
+			}
+		}
+	}
+	return keyARNs
+}
+
+// kmsViaServices returns the S3 service endpoints the keys may be used through.
+// SSE-KMS keys always live in the same region as the bucket.
+func kmsViaServices(keyARNs []string) StringList {
+	seen := make(map[string]bool)
+	var services StringList
+	for _, keyARN := range keyARNs {
+		key, err := ParseKMSKeyARN(keyARN)
+		if err != nil {
+			continue
+		}
+		service := fmt.Sprintf("s3.%s.%s", key.Region, dnsSuffix(key.Partition))
+		if !seen[service] {
+			seen[service] = true
+			services = append(services, service)
+		}
+	}
+	sort.Strings(services)
+	return services
+}
+
+// kmsStatement grants the KMS actions on the keys, only when called by S3
+func kmsStatement(actions, keyARNs []string) RoleStatement {
+	return RoleStatement{
+		Sid:      "CriblKMSAccess",
+		Effect:   "Allow",
+		Action:   actions,
+		Resource: keyARNs,
+		Condition: &Condition{
+			StringEquals: map[string]StringList{
+				"kms:ViaService": kmsViaServices(keyARNs),
+			},
+		},
+	}
+}
+
+type keyPolicyStatement struct {
+	Sid       string    `json:"Sid"`
+	Effect    string    `json:"Effect"`
+	Principal Principal `json:"Principal"`
+	Action    []string  `json:"Action"`
+	Resource  string    `json:"Resource"`
+	Condition Condition `json:"Condition"`
+}
+
+// KMSKeyPolicyStatement returns the statement that must be added to the key
+// policy of each key so roleARN can use it through S3 for action.
+func KMSKeyPolicyStatement(roleARN, action string, keyARNs []string) (string, error) {
+	if err := ValidateAction(action); err != nil {
+		return "", err
+	}
+
+	statement := keyPolicyStatement{
+		Sid:       "AllowCriblRoleUseThroughS3",
+		Effect:    "Allow",
+		Principal: Principal{AWS: StringList{roleARN}},
+		Action:    actionPermissions[action].KMS,
+		Resource:  "*",
+		Condition: Condition{
+			StringEquals: map[string]StringList{
+				"kms:ViaService": kmsViaServices(keyARNs),
+			},
+		},
+	}
+
+	data, err := json.MarshalIndent(statement, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal key policy statement: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,92 @@
+// pkg/aws/principal.go
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+)
+
+// searchExecRolePrefix is the role name prefix of a workspace's Cribl Search exec role
+const searchExecRolePrefix = "search-exec-"
+
+// ErrAmbiguousPrincipal is returned for role names with several hyphens when no
+// workspace hint tells where the workspace name ends
+var ErrAmbiguousPrincipal = errors.New("ambiguous workspace/worker group, pass --workspace")
+
+// ParseCriblPrincipalARN parses the ARN of a Cribl role of the form
+// arn:PARTITION:iam::ACCOUNT:role/PATH/WORKSPACE-WORKERGROUP or
+// arn:PARTITION:iam::ACCOUNT:role/PATH/search-exec-WORKSPACE.
+// Workspace and worker group names may both contain hyphens. A role name that
+// starts with one of the given workspaces followed by a hyphen is split there;
+// otherwise it must contain a single hyphen. Names with more hyphens return
+// ErrAmbiguousPrincipal together with the principal's account, partition and
+// path, so callers reading existing roles can still recognise them.
+func ParseCriblPrincipalARN(principalARN string, workspaces ...string) (TrustedPrincipal, error) {
+	parsed, err := arn.Parse(principalARN)
+	if err != nil {
+		return TrustedPrincipal{}, fmt.Errorf("invalid ARN '%s': expected arn:PARTITION:iam::ACCOUNT:role/NAME", principalARN)
+	}
+	if err := ValidatePartition(parsed.Partition); err != nil {
+		return TrustedPrincipal{}, fmt.Errorf("invalid ARN '%s': %w", principalARN, err)
+	}
+	if parsed.Service != "iam" || parsed.Region != "" {
+		return TrustedPrincipal{}, fmt.Errorf("invalid ARN '%s': expected an IAM role ARN without a region", principalARN)
+	}
+	if err := ValidateAccountID(parsed.AccountID); err != nil {
+		return TrustedPrincipal{}, fmt.Errorf("invalid ARN '%s': %w", principalARN, err)
+	}
+
+	resource, ok := strings.CutPrefix(parsed.Resource, "role/")
+	if !ok {
+		return TrustedPrincipal{}, fmt.Errorf("invalid ARN '%s': expected a role resource, got '%s'", principalARN, parsed.Resource)
+	}
+	path := "/"
+	name := resource
+	if i := strings.LastIndex(resource, "/"); i >= 0 {
+		path = "/" + resource[:i+1]
+		name = resource[i+1:]
+	}
+	if len(path) > maxRolePathLength || !rolePathPattern.MatchString(path) {
+		return TrustedPrincipal{}, fmt.Errorf("invalid ARN '%s': invalid role path '%s'", principalARN, path)
+	}
+	if err := ValidateRoleName(name); err != nil {
+		return TrustedPrincipal{}, fmt.Errorf("invalid ARN '%s': %w", principalARN, err)
+	}
+
+	principal := TrustedPrincipal{
+		Partition: parsed.Partition,
+		AccountID: parsed.AccountID,
+		Path:      path,
+	}
+
+	// Search exec roles only carry the workspace
+	if workspace, ok := strings.CutPrefix(name, searchExecRolePrefix); ok {
+		if workspace == "" {
+			return TrustedPrincipal{}, fmt.Errorf("invalid ARN '%s': expected role name search-exec-WORKSPACE", principalARN)
+		}
+		principal.Workspace = workspace
+		principal.SearchExec = true
+		return principal, nil
+	}
+
+	for _, workspace := range workspaces {
+		if workergroup, ok := strings.CutPrefix(name, workspace+"-"); ok && workspace != "" && workergroup != "" {
+			principal.Workspace = workspace
+			principal.Workergroup = workergroup
+			return principal, nil
+		}
+	}
+	if strings.Count(name, "-") > 1 {
+		return principal, fmt.Errorf("invalid ARN '%s': role name '%s': %w", principalARN, name, ErrAmbiguousPrincipal)
+	}
+	workspace, workergroup, ok := strings.Cut(name, "-")
+	if !ok || workspace == "" || workergroup == "" {
+		return TrustedPrincipal{}, fmt.Errorf("invalid ARN '%s': expected role name WORKSPACE-WORKERGROUP or search-exec-WORKSPACE, got '%s'", principalARN, name)
+	}
+	principal.Workspace = workspace
+	principal.Workergroup = workergroup
+	return principal, nil
+}
@@ -0,0 +1,124 @@
+// pkg/aws/principal_test.go
+package aws
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseCriblPrincipalARN(t *testing.T) {
+	tests := []struct {
+		name        string
+		arn         string
+		workspaces  []string
+		workspace   string
+		workergroup string
+		searchExec  bool
+		path        string
+		ambiguous   bool
+		wantErr     bool
+	}{
+		{
+			name:        "single hyphen",
+			arn:         "arn:aws:iam::123456789012:role/main-default",
+			workspace:   "main",
+			workergroup: "default",
+			path:        "/",
+		},
+		{
+			name:        "role path",
+			arn:         "arn:aws:iam::123456789012:role/cribl/main-default",
+			workspace:   "main",
+			workergroup: "default",
+			path:        "/cribl/",
+		},
+		{
+			name:       "search exec role",
+			arn:        "arn:aws:iam::123456789012:role/search-exec-prod-east",
+			workspace:  "prod-east",
+			searchExec: true,
+			path:       "/",
+		},
+		{
+			name:      "several hyphens without hint",
+			arn:       "arn:aws:iam::123456789012:role/prod-east-default",
+			ambiguous: true,
+		},
+		{
+			name:       "several hyphens with unrelated hint",
+			arn:        "arn:aws:iam::123456789012:role/prod-east-default",
+			workspaces: []string{"main"},
+			ambiguous:  true,
+		},
+		{
+			name:        "hint names hyphenated workspace",
+			arn:         "arn:aws:iam::123456789012:role/prod-east-default",
+			workspaces:  []string{"prod-east"},
+			workspace:   "prod-east",
+			workergroup: "default",
+			path:        "/",
+		},
+		{
+			name:        "hint names workspace of hyphenated worker group",
+			arn:         "arn:aws:iam::123456789012:role/prod-east-default",
+			workspaces:  []string{"prod"},
+			workspace:   "prod",
+			workergroup: "east-default",
+			path:        "/",
+		},
+		{
+			name:    "no hyphen",
+			arn:     "arn:aws:iam::123456789012:role/default",
+			wantErr: true,
+		},
+		{
+			name:    "short account",
+			arn:     "arn:aws:iam::12345:role/main-default",
+			wantErr: true,
+		},
+		{
+			name:    "unknown partition",
+			arn:     "arn:aws-xx:iam::123456789012:role/main-default",
+			wantErr: true,
+		},
+		{
+			name:    "user resource",
+			arn:     "arn:aws:iam::123456789012:user/main-default",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := ParseCriblPrincipalARN(tt.arn, tt.workspaces...)
+			switch {
+			case tt.ambiguous:
+				if !errors.Is(err, ErrAmbiguousPrincipal) {
+					t.Fatalf("expected ErrAmbiguousPrincipal, got %v", err)
+				}
+				if principal.AccountID != "123456789012" || principal.Workspace != "" {
+					t.Errorf("expected only the account to be set, got %+v", principal)
+				}
+				return
+			case tt.wantErr:
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", principal)
+				}
+				return
+			case err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if principal.Workspace != tt.workspace || principal.Workergroup != tt.workergroup || principal.SearchExec != tt.searchExec {
+				t.Errorf("got workspace %q, worker group %q, search exec %v; want %q, %q, %v",
+					principal.Workspace, principal.Workergroup, principal.SearchExec, tt.workspace, tt.workergroup, tt.searchExec)
+			}
+			if principal.Path != tt.path {
+				t.Errorf("got path %q, want %q", principal.Path, tt.path)
+			}
+			if principal.AccountID != "123456789012" || principal.Partition != "aws" {
+				t.Errorf("got account %q, partition %q", principal.AccountID, principal.Partition)
+			}
+		})
+	}
+}
@@ -4,15 +4,38 @@ package aws
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 )
 
-// Bucket represents an S3 bucket without the creation date
+// Bucket represents an S3 bucket. Everything but the name, creation date and
+// region is only filled in by DescribeBuckets.
 type Bucket struct {
-	Name string `json:"name"`
+	Name              string             `json:"name"`
+	CreationDate      *time.Time         `json:"creation_date,omitempty"`
+	Region            string             `json:"region,omitempty"`
+	Encryption        string             `json:"encryption,omitempty"`
+	KMSKeyID          string             `json:"kms_key_id,omitempty"`
+	Versioning        string             `json:"versioning,omitempty"`
+	ObjectLock        *bool              `json:"object_lock,omitempty"`
+	PublicAccessBlock *PublicAccessBlock `json:"public_access_block,omitempty"`
+	// Error is set when some of the details could not be read
+	Error string `json:"error,omitempty"`
+}
+
+// PublicAccessBlock is the public access block configuration of a bucket
+type PublicAccessBlock struct {
+	BlockPublicAcls       bool `json:"block_public_acls"`
+	IgnorePublicAcls      bool `json:"ignore_public_acls"`
+	BlockPublicPolicy     bool `json:"block_public_policy"`
+	RestrictPublicBuckets bool `json:"restrict_public_buckets"`
 }
 
 // S3Client wraps the AWS S3 client
@@ -27,23 +50,148 @@ func NewS3Client(cfg aws.Config) *S3Client {
 	}
 }
 
-// ListBuckets retrieves the list of S3 buckets
-func (c *S3Client) ListBuckets() ([]Bucket, error) {
-	input := &s3.ListBucketsInput{}
-	result, err := c.Client.ListBuckets(context.TODO(), input)
-	if err != nil {
-		return nil, err
+// listBucketsPageSize is the number of buckets requested per ListBuckets page
+const listBucketsPageSize = 1000
+
+// ListBuckets retrieves the S3 buckets whose names start with prefix, following
+// every page so accounts with more than 10,000 buckets are fully listed. With
+// regions set, only buckets in those regions are returned.
+func (c *S3Client) ListBuckets(prefix string, regions []string) ([]Bucket, error) {
+	if len(regions) == 0 {
+		return c.listBuckets(prefix, "")
 	}
 
 	var buckets []Bucket
-	for _, b := range result.Buckets {
-		buckets = append(buckets, Bucket{
-			Name: aws.ToString(b.Name),
-		})
+	for _, region := range regions {
+		regionBuckets, err := c.listBuckets(prefix, region)
+		if err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, regionBuckets...)
 	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].Name < buckets[j].Name
+	})
 	return buckets, nil
 }
 
+// listBuckets lists the buckets with prefix, limited to region if it is set
+func (c *S3Client) listBuckets(prefix, region string) ([]Bucket, error) {
+	input := &s3.ListBucketsInput{
+		MaxBuckets: aws.Int32(listBucketsPageSize),
+	}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+	// A BucketRegion filter is only supported by that region's endpoint
+	var optFns []func(*s3.Options)
+	if region != "" {
+		input.BucketRegion = aws.String(region)
+		optFns = append(optFns, withRegion(region))
+	}
+
+	var buckets []Bucket
+	paginator := s3.NewListBucketsPaginator(c.Client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO(), optFns...)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range page.Buckets {
+			buckets = append(buckets, Bucket{
+				Name:         aws.ToString(b.Name),
+				CreationDate: b.CreationDate,
+				Region:       aws.ToString(b.BucketRegion),
+			})
+		}
+	}
+	return buckets, nil
+}
+
+// GetBucketRegion returns the region a bucket lives in
+func (c *S3Client) GetBucketRegion(bucket string) (string, error) {
+	result, err := c.Client.GetBucketLocation(context.TODO(), &s3.GetBucketLocationInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get location of bucket '%s': %w", bucket, err)
+	}
+
+	// Buckets in us-east-1 have no location constraint, and EU is the legacy name for eu-west-1
+	switch result.LocationConstraint {
+	case "":
+		return "us-east-1", nil
+	case types.BucketLocationConstraintEu:
+		return "eu-west-1", nil
+	default:
+		return string(result.LocationConstraint), nil
+	}
+}
+
+// AWSManagedS3KeyAlias is returned by GetBucketKMSKey for buckets encrypted
+// with the AWS managed key of S3, which cannot be used by other accounts
+const AWSManagedS3KeyAlias = "alias/aws/s3"
+
+// GetBucketKMSKey returns the KMS key used for default encryption of a bucket,
+// AWSManagedS3KeyAlias if SSE-KMS uses the AWS managed key, or an empty string
+// if the bucket is not encrypted with SSE-KMS
+func (c *S3Client) GetBucketKMSKey(bucket string) (string, error) {
+	region, err := c.GetBucketRegion(bucket)
+	if err != nil {
+		return "", err
+	}
+
+	algorithm, keyID, err := c.getBucketEncryption(bucket, region)
+	if err != nil {
+		return "", err
+	}
+	if algorithm != types.ServerSideEncryptionAwsKms && algorithm != types.ServerSideEncryptionAwsKmsDsse {
+		return "", nil
+	}
+	// Without a key ID, S3 uses its AWS managed key
+	if keyID == "" || keyID == AWSManagedS3KeyAlias {
+		return AWSManagedS3KeyAlias, nil
+	}
+	return keyID, nil
+}
+
+// getBucketEncryption returns the default encryption algorithm of a bucket and
+// its KMS key, if any. The algorithm is empty if no default encryption is set.
+func (c *S3Client) getBucketEncryption(bucket, region string) (types.ServerSideEncryption, string, error) {
+	result, err := c.Client.GetBucketEncryption(context.TODO(), &s3.GetBucketEncryptionInput{
+		Bucket: aws.String(bucket),
+	}, withRegion(region))
+	if err != nil {
+		if isAPIError(err, "ServerSideEncryptionConfigurationNotFoundError") {
+			return "", "", nil
+		}
+		return "", "", fmt.Errorf("failed to get encryption of bucket '%s': %w", bucket, err)
+	}
+
+	if result.ServerSideEncryptionConfiguration == nil {
+		return "", "", nil
+	}
+	for _, rule := range result.ServerSideEncryptionConfiguration.Rules {
+		if sse := rule.ApplyServerSideEncryptionByDefault; sse != nil {
+			return sse.SSEAlgorithm, aws.ToString(sse.KMSMasterKeyID), nil
+		}
+	}
+	return "", "", nil
+}
+
+// isAPIError reports whether err is an AWS API error with the given code
+func isAPIError(err error, code string) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == code
+}
+
+// withRegion sends a request to the region a bucket lives in
+func withRegion(region string) func(*s3.Options) {
+	return func(o *s3.Options) {
+		o.Region = region
+	}
+}
+
 // PrintBucketsText prints the list of buckets in text format
 func (c *S3Client) PrintBucketsText(buckets []Bucket) {
 	fmt.Println("Listing S3 Buckets:")
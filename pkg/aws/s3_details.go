@@ -0,0 +1,203 @@
+// pkg/aws/s3_details.go
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// DefaultDetailsConcurrency is the number of buckets DescribeBuckets inspects at once
+const DefaultDetailsConcurrency = 10
+
+// DescribeBuckets fills in the region, encryption, versioning, Object Lock and
+// public access block settings of each bucket, inspecting up to concurrency
+// buckets at once. A bucket whose settings cannot be read keeps the details
+// that could be read and has Error set, so one inaccessible bucket does not
+// fail the whole listing.
+func (c *S3Client) DescribeBuckets(buckets []Bucket, concurrency int) []Bucket {
+	if concurrency < 1 {
+		concurrency = DefaultDetailsConcurrency
+	}
+
+	result := make([]Bucket, len(buckets))
+	copy(result, buckets)
+
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	for i := 0; i < min(concurrency, len(result)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				c.describeBucket(&result[j])
+			}
+		}()
+	}
+	for i := range result {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return result
+}
+
+// describeBucket reads the details of one bucket. The region is looked up
+// unless ListBuckets already returned it, and every other request is sent to
+// the bucket's own region.
+func (c *S3Client) describeBucket(bucket *Bucket) {
+	region := bucket.Region
+	if region == "" {
+		var err error
+		if region, err = c.GetBucketRegion(bucket.Name); err != nil {
+			bucket.Error = err.Error()
+			return
+		}
+		bucket.Region = region
+	}
+
+	var errs []error
+	algorithm, keyID, err := c.getBucketEncryption(bucket.Name, region)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	bucket.Encryption = string(algorithm)
+	bucket.KMSKeyID = keyID
+
+	if bucket.Versioning, err = c.getBucketVersioning(bucket.Name, region); err != nil {
+		errs = append(errs, err)
+	}
+	if bucket.ObjectLock, err = c.getBucketObjectLock(bucket.Name, region); err != nil {
+		errs = append(errs, err)
+	}
+	if bucket.PublicAccessBlock, err = c.getPublicAccessBlock(bucket.Name, region); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		bucket.Error = strings.ReplaceAll(err.Error(), "\n", "; ")
+	}
+}
+
+// getBucketVersioning returns Enabled, Suspended or Disabled for buckets that
+// never had versioning turned on
+func (c *S3Client) getBucketVersioning(bucket, region string) (string, error) {
+	result, err := c.Client.GetBucketVersioning(context.TODO(), &s3.GetBucketVersioningInput{
+		Bucket: aws.String(bucket),
+	}, withRegion(region))
+	if err != nil {
+		return "", fmt.Errorf("failed to get versioning of bucket '%s': %w", bucket, err)
+	}
+	if result.Status == "" {
+		return "Disabled", nil
+	}
+	return string(result.Status), nil
+}
+
+// getBucketObjectLock reports whether Object Lock is enabled on a bucket
+func (c *S3Client) getBucketObjectLock(bucket, region string) (*bool, error) {
+	result, err := c.Client.GetObjectLockConfiguration(context.TODO(), &s3.GetObjectLockConfigurationInput{
+		Bucket: aws.String(bucket),
+	}, withRegion(region))
+	if err != nil {
+		if isAPIError(err, "ObjectLockConfigurationNotFoundError") {
+			return aws.Bool(false), nil
+		}
+		return nil, fmt.Errorf("failed to get Object Lock configuration of bucket '%s': %w", bucket, err)
+	}
+	enabled := result.ObjectLockConfiguration != nil &&
+		result.ObjectLockConfiguration.ObjectLockEnabled == types.ObjectLockEnabledEnabled
+	return aws.Bool(enabled), nil
+}
+
+// getPublicAccessBlock returns the public access block configuration of a
+// bucket, with every setting off if the bucket has none
+func (c *S3Client) getPublicAccessBlock(bucket, region string) (*PublicAccessBlock, error) {
+	result, err := c.Client.GetPublicAccessBlock(context.TODO(), &s3.GetPublicAccessBlockInput{
+		Bucket: aws.String(bucket),
+	}, withRegion(region))
+	if err != nil {
+		if isAPIError(err, "NoSuchPublicAccessBlockConfiguration") {
+			return &PublicAccessBlock{}, nil
+		}
+		return nil, fmt.Errorf("failed to get public access block of bucket '%s': %w", bucket, err)
+	}
+	config := result.PublicAccessBlockConfiguration
+	if config == nil {
+		return &PublicAccessBlock{}, nil
+	}
+	return &PublicAccessBlock{
+		BlockPublicAcls:       aws.ToBool(config.BlockPublicAcls),
+		IgnorePublicAcls:      aws.ToBool(config.IgnorePublicAcls),
+		BlockPublicPolicy:     aws.ToBool(config.BlockPublicPolicy),
+		RestrictPublicBuckets: aws.ToBool(config.RestrictPublicBuckets),
+	}, nil
+}
+
+// Summary describes the public access block as all, none or partial
+func (p *PublicAccessBlock) Summary() string {
+	if p == nil {
+		return "-"
+	}
+	count := 0
+	for _, enabled := range []bool{p.BlockPublicAcls, p.IgnorePublicAcls, p.BlockPublicPolicy, p.RestrictPublicBuckets} {
+		if enabled {
+			count++
+		}
+	}
+	switch count {
+	case 4:
+		return "all"
+	case 0:
+		return "none"
+	default:
+		return "partial"
+	}
+}
+
+// PrintBucketsDetails prints the buckets and their details as a table
+func (c *S3Client) PrintBucketsDetails(buckets []Bucket) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tREGION\tCREATED\tENCRYPTION\tKMS KEY\tVERSIONING\tOBJECT LOCK\tPUBLIC ACCESS BLOCK")
+	for _, bucket := range buckets {
+		created := "-"
+		if bucket.CreationDate != nil {
+			created = bucket.CreationDate.Format("2006-01-02")
+		}
+		objectLock := "-"
+		if bucket.ObjectLock != nil {
+			objectLock = yesNo(*bucket.ObjectLock)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			bucket.Name,
+			valueOrDash(bucket.Region),
+			created,
+			valueOrDash(bucket.Encryption),
+			valueOrDash(bucket.KMSKeyID),
+			valueOrDash(bucket.Versioning),
+			objectLock,
+			bucket.PublicAccessBlock.Summary())
+	}
+	w.Flush()
+
+	for _, bucket := range buckets {
+		if bucket.Error != "" {
+			fmt.Printf("! %s: %s\n", bucket.Name, bucket.Error)
+		}
+	}
+}
+
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,154 @@
+// pkg/aws/s3_objects.go
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// listObjectsPageSize is the largest number of keys ListObjectsV2 returns per page
+const listObjectsPageSize = 1000
+
+// Object is an object in a bucket
+type Object struct {
+	Key          string     `json:"key"`
+	Size         int64      `json:"size"`
+	LastModified *time.Time `json:"last_modified,omitempty"`
+	StorageClass string     `json:"storage_class,omitempty"`
+	ETag         string     `json:"etag,omitempty"`
+}
+
+// ObjectListing is the content of a bucket under a prefix. Unless the listing
+// is recursive, keys below the next "/" are grouped into CommonPrefixes.
+type ObjectListing struct {
+	Bucket         string   `json:"bucket"`
+	Prefix         string   `json:"prefix"`
+	CommonPrefixes []string `json:"common_prefixes"`
+	Objects        []Object `json:"objects"`
+	// Truncated is set when maxKeys stopped the listing before the end
+	Truncated bool `json:"truncated"`
+}
+
+// ListObjects lists the objects of a bucket under prefix, following every page
+// until maxKeys prefixes and objects were returned, or all of them if maxKeys
+// is 0. The request is sent to the bucket's own region.
+func (c *S3Client) ListObjects(bucket, prefix string, recursive bool, maxKeys int) (*ObjectListing, error) {
+	region, err := c.GetBucketRegion(bucket)
+	if err != nil {
+		return nil, err
+	}
+	return c.listObjects(bucket, region, prefix, recursive, maxKeys)
+}
+
+// listObjects lists the objects of a bucket in a known region
+func (c *S3Client) listObjects(bucket, region, prefix string, recursive bool, maxKeys int) (*ObjectListing, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucket),
+		MaxKeys: aws.Int32(listObjectsPageSize),
+	}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+	if !recursive {
+		input.Delimiter = aws.String("/")
+	}
+	if maxKeys > 0 && maxKeys < listObjectsPageSize {
+		input.MaxKeys = aws.Int32(int32(maxKeys))
+	}
+
+	listing := &ObjectListing{
+		Bucket:         bucket,
+		Prefix:         prefix,
+		CommonPrefixes: []string{},
+		Objects:        []Object{},
+	}
+	count := 0
+	paginator := s3.NewListObjectsV2Paginator(c.Client, input)
+	for paginator.HasMorePages() {
+		if maxKeys > 0 && count >= maxKeys {
+			listing.Truncated = true
+			break
+		}
+		page, err := paginator.NextPage(context.TODO(), withRegion(region))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects in bucket '%s': %w", bucket, err)
+		}
+		for _, commonPrefix := range page.CommonPrefixes {
+			if maxKeys > 0 && count >= maxKeys {
+				listing.Truncated = true
+				break
+			}
+			listing.CommonPrefixes = append(listing.CommonPrefixes, aws.ToString(commonPrefix.Prefix))
+			count++
+		}
+		for _, object := range page.Contents {
+			if maxKeys > 0 && count >= maxKeys {
+				listing.Truncated = true
+				break
+			}
+			listing.Objects = append(listing.Objects, Object{
+				Key:          aws.ToString(object.Key),
+				Size:         aws.ToInt64(object.Size),
+				LastModified: object.LastModified,
+				StorageClass: string(object.StorageClass),
+				ETag:         strings.Trim(aws.ToString(object.ETag), `"`),
+			})
+			count++
+		}
+	}
+	return listing, nil
+}
+
+// PrintText prints the common prefixes followed by the objects, with keys
+// relative to the listed prefix
+func (l *ObjectListing) PrintText() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, prefix := range l.CommonPrefixes {
+		fmt.Fprintf(w, "\t\tPRE\t\t%s\n", strings.TrimPrefix(prefix, l.Prefix))
+	}
+	for _, object := range l.Objects {
+		modified := "-"
+		if object.LastModified != nil {
+			modified = object.LastModified.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			modified,
+			FormatBytes(object.Size),
+			valueOrDash(object.StorageClass),
+			valueOrDash(object.ETag),
+			strings.TrimPrefix(object.Key, l.Prefix))
+	}
+	w.Flush()
+
+	if l.Truncated {
+		fmt.Println("(listing truncated by --max-keys)")
+	}
+}
+
+// PrintJSON prints the listing in JSON format
+func (l *ObjectListing) PrintJSON() error {
+	jsonData, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(jsonData))
+	return nil
+}
+
+// PrintNamesOnly prints the full key of every common prefix and object, one per line
+func (l *ObjectListing) PrintNamesOnly() {
+	for _, prefix := range l.CommonPrefixes {
+		fmt.Println(prefix)
+	}
+	for _, object := range l.Objects {
+		fmt.Println(object.Key)
+	}
+}
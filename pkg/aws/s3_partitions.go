@@ -0,0 +1,572 @@
+// pkg/aws/s3_partitions.go
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Kinds of path segments found by InferPartitionLayout
+const (
+	SegmentLiteral  = "literal"
+	SegmentTime     = "time"
+	SegmentHive     = "hive"
+	SegmentVariable = "variable"
+)
+
+// DefaultPartitionSampleSize is the number of keys sampled from the start of a prefix
+const DefaultPartitionSampleSize = 1000
+
+// maxPartitionDepth bounds the walk to the newest key
+const maxPartitionDepth = 32
+
+// maxSegmentExamples is the number of distinct values reported per segment
+const maxSegmentExamples = 3
+
+// timeTokens maps strftime tokens, as used in Cribl ${_time:...} expressions,
+// to Go time layouts, from the coarsest to the finest
+var timeTokens = []struct {
+	Token  string
+	Layout string
+}{
+	{"%Y", "2006"},
+	{"%m", "01"},
+	{"%d", "02"},
+	{"%H", "15"},
+	{"%M", "04"},
+}
+
+// Date formats recognised in a single path segment
+var combinedTimeFormats = []struct {
+	Pattern *regexp.Regexp
+	Format  string
+}{
+	{regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}$`), "%Y-%m-%dT%H"},
+	{regexp.MustCompile(`^\d{4}-\d{2}-\d{2}-\d{2}$`), "%Y-%m-%d-%H"},
+	{regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`), "%Y-%m-%d"},
+	{regexp.MustCompile(`^\d{10}$`), "%Y%m%d%H"},
+	{regexp.MustCompile(`^\d{8}$`), "%Y%m%d"},
+	{regexp.MustCompile(`^\d{4}-\d{2}$`), "%Y-%m"},
+}
+
+// Hive partition keys that hold a time component
+var hiveTimeKeys = map[string]string{
+	"year":   "%Y",
+	"yr":     "%Y",
+	"y":      "%Y",
+	"month":  "%m",
+	"mo":     "%m",
+	"m":      "%m",
+	"day":    "%d",
+	"d":      "%d",
+	"dd":     "%d",
+	"hour":   "%H",
+	"hr":     "%H",
+	"h":      "%H",
+	"hh":     "%H",
+	"minute": "%M",
+	"min":    "%M",
+}
+
+var (
+	hiveSegmentPattern = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_.-]*)=(.*)$`)
+	regionPattern      = regexp.MustCompile(`^[a-z]{2}(-gov)?-[a-z]+-\d$`)
+)
+
+// PartitionLayout is the partition scheme inferred from the keys under a prefix
+type PartitionLayout struct {
+	Bucket      string             `json:"bucket"`
+	Prefix      string             `json:"prefix"`
+	SampledKeys int                `json:"sampled_keys"`
+	MatchedKeys int                `json:"matched_keys"`
+	Segments    []PartitionSegment `json:"segments"`
+	// Template is the partition expression relative to Prefix, such as
+	// ${_time:%Y}/${_time:%m}/${host}
+	Template string `json:"template"`
+	// SearchPath is the path expression for a Cribl Search S3 dataset
+	SearchPath  string     `json:"search_path"`
+	Earliest    *time.Time `json:"earliest,omitempty"`
+	Latest      *time.Time `json:"latest,omitempty"`
+	Granularity string     `json:"granularity,omitempty"`
+	// LatestIsLowerBound is set when a level held more objects than were read
+	// on the way to the newest key, so newer partitions may exist
+	LatestIsLowerBound bool `json:"latest_is_lower_bound,omitempty"`
+}
+
+// PartitionSegment is one directory level of the layout
+type PartitionSegment struct {
+	Kind     string   `json:"kind"`
+	Template string   `json:"template"`
+	Examples []string `json:"examples"`
+	// Format is the strftime format of time segments, such as %Y-%m-%d
+	Format string `json:"format,omitempty"`
+	// HiveKey is the key of key=value segments
+	HiveKey string `json:"hive_key,omitempty"`
+}
+
+// DiscoverPartitions samples the keys under prefix and infers their partition
+// layout. The first sampleSize keys are read, along with the newest key found
+// by following the last prefix at every level, so the time coverage spans the
+// whole prefix.
+func (c *S3Client) DiscoverPartitions(bucket, prefix string, sampleSize int) (*PartitionLayout, error) {
+	if sampleSize < 1 {
+		sampleSize = DefaultPartitionSampleSize
+	}
+	region, err := c.GetBucketRegion(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	listing, err := c.listObjects(bucket, region, prefix, true, sampleSize)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, object := range listing.Objects {
+		keys = append(keys, object.Key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no objects found in bucket '%s' under prefix '%s'", bucket, prefix)
+	}
+
+	newest, complete, err := c.newestKey(bucket, region, prefix, sampleSize)
+	if err != nil {
+		return nil, err
+	}
+	if newest != "" && !containsString(keys, newest) {
+		keys = append(keys, newest)
+	}
+
+	layout := InferPartitionLayout(prefix, keys)
+	layout.LatestIsLowerBound = !complete && layout.Latest != nil
+	layout.Bucket = bucket
+	layout.SearchPath = bucket + "/" + layout.Prefix + layout.Template
+	return layout, nil
+}
+
+// newestKey follows the lexically last common prefix at every level below
+// prefix and returns a key found at the bottom. Zero-padded time partitions
+// sort by time, so this is the newest partition. Every common prefix of a level
+// is paged through, but only up to sampleSize objects are read per level, so a
+// flat prefix or a large leaf partition is never listed in full; time only
+// comes from the directories, so any key of the leaf will do. The result is
+// false when a level was cut short, since a later prefix may have been missed.
+func (c *S3Client) newestKey(bucket, region, prefix string, sampleSize int) (string, bool, error) {
+	complete := true
+	for depth := 0; depth < maxPartitionDepth; depth++ {
+		input := &s3.ListObjectsV2Input{
+			Bucket:    aws.String(bucket),
+			Delimiter: aws.String("/"),
+			MaxKeys:   aws.Int32(listObjectsPageSize),
+		}
+		if prefix != "" {
+			input.Prefix = aws.String(prefix)
+		}
+
+		lastPrefix, lastKey := "", ""
+		objects := 0
+		paginator := s3.NewListObjectsV2Paginator(c.Client, input)
+		for paginator.HasMorePages() {
+			if objects >= sampleSize {
+				complete = false
+				break
+			}
+			page, err := paginator.NextPage(context.TODO(), withRegion(region))
+			if err != nil {
+				return "", false, fmt.Errorf("failed to list objects in bucket '%s': %w", bucket, err)
+			}
+			if n := len(page.CommonPrefixes); n > 0 {
+				lastPrefix = aws.ToString(page.CommonPrefixes[n-1].Prefix)
+			}
+			if n := len(page.Contents); n > 0 {
+				lastKey = aws.ToString(page.Contents[n-1].Key)
+			}
+			objects += len(page.Contents)
+		}
+		if lastPrefix == "" {
+			return lastKey, complete, nil
+		}
+		prefix = lastPrefix
+	}
+	return "", complete, nil
+}
+
+// InferPartitionLayout infers the partition layout of keys below prefix. Only
+// the directories are considered, and only keys at the most common depth. A
+// prefix that does not end with "/" is cut back to the last "/".
+func InferPartitionLayout(prefix string, keys []string) *PartitionLayout {
+	base := prefix[:strings.LastIndex(prefix, "/")+1]
+	layout := &PartitionLayout{
+		Prefix:      base,
+		SampledKeys: len(keys),
+		Segments:    []PartitionSegment{},
+	}
+
+	// Group the directory segments of each key by depth
+	byDepth := make(map[int][][]string)
+	for _, key := range keys {
+		dirs := strings.Split(strings.TrimPrefix(key, base), "/")
+		dirs = dirs[:len(dirs)-1]
+		byDepth[len(dirs)] = append(byDepth[len(dirs)], dirs)
+	}
+	depth := 0
+	for d, paths := range byDepth {
+		if len(paths) > len(byDepth[depth]) || len(paths) == len(byDepth[depth]) && d > depth {
+			depth = d
+		}
+	}
+	paths := byDepth[depth]
+	layout.MatchedKeys = len(paths)
+
+	previousToken := ""
+	var templates []string
+	for i := 0; i < depth; i++ {
+		values := make([]string, 0, len(paths))
+		for _, path := range paths {
+			values = append(values, path[i])
+		}
+		segment := classifySegment(i, values, previousToken)
+		previousToken = ""
+		if segment.Kind == SegmentTime {
+			previousToken = lastTimeToken(segment.Format)
+		}
+		layout.Segments = append(layout.Segments, segment)
+		templates = append(templates, segment.Template)
+	}
+	if depth > 0 {
+		layout.Template = strings.Join(templates, "/") + "/"
+	}
+
+	layout.Earliest, layout.Latest, layout.Granularity = timeCoverage(layout.Segments, paths)
+	return layout
+}
+
+// classifySegment determines the kind of the i-th directory level from its
+// values. Time tokens are preferred over literals, since a sample often lies
+// within a single year or month; a month or finer token is only recognised
+// right after the next coarser one.
+func classifySegment(i int, values []string, previousToken string) PartitionSegment {
+	segment := PartitionSegment{Examples: distinctExamples(values)}
+
+	// key=value Hive segments
+	if key, ok := commonHiveKey(values); ok {
+		segment.Kind = SegmentHive
+		segment.HiveKey = key
+		hiveValues := make([]string, 0, len(values))
+		for _, value := range values {
+			hiveValues = append(hiveValues, strings.SplitN(value, "=", 2)[1])
+		}
+		if format, ok := hiveTimeKeys[strings.ToLower(key)]; ok && allMatchTime(hiveValues, format) {
+			segment.Format = format
+		} else if format, ok := combinedTimeFormat(hiveValues); ok {
+			segment.Format = format
+		}
+		if segment.Format != "" {
+			segment.Template = key + "=" + timeTemplate(segment.Format)
+		} else {
+			segment.Template = key + "=${" + key + "}"
+		}
+		return segment
+	}
+
+	if format, ok := combinedTimeFormat(values); ok {
+		segment.Kind = SegmentTime
+		segment.Format = format
+		segment.Template = timeTemplate(format)
+		return segment
+	}
+
+	if format, ok := nextTimeToken(previousToken); ok && allMatchTime(values, format) {
+		segment.Kind = SegmentTime
+		segment.Format = format
+		segment.Template = timeTemplate(format)
+		return segment
+	}
+
+	if len(segment.Examples) == 1 {
+		segment.Kind = SegmentLiteral
+		segment.Template = values[0]
+		return segment
+	}
+
+	segment.Kind = SegmentVariable
+	segment.Template = "${" + variableName(i, values) + "}"
+	return segment
+}
+
+// commonHiveKey returns the key if every value is a key=value pair with the same key
+func commonHiveKey(values []string) (string, bool) {
+	key := ""
+	for _, value := range values {
+		match := hiveSegmentPattern.FindStringSubmatch(value)
+		if match == nil || key != "" && match[1] != key {
+			return "", false
+		}
+		key = match[1]
+	}
+	return key, key != ""
+}
+
+// combinedTimeFormat returns the format if every value is a date in the same
+// multi-token format, such as 2024-05-01
+func combinedTimeFormat(values []string) (string, bool) {
+	for _, candidate := range combinedTimeFormats {
+		matched := true
+		for _, value := range values {
+			if !candidate.Pattern.MatchString(value) {
+				matched = false
+				break
+			}
+		}
+		if matched && allMatchTime(values, candidate.Format) {
+			return candidate.Format, true
+		}
+	}
+	return "", false
+}
+
+// nextTimeToken returns the time token that may follow previous. Only a year
+// may start a time hierarchy.
+func nextTimeToken(previous string) (string, bool) {
+	if previous == "" {
+		return "%Y", true
+	}
+	for i, token := range timeTokens[:len(timeTokens)-1] {
+		if token.Token == previous {
+			return timeTokens[i+1].Token, true
+		}
+	}
+	return "", false
+}
+
+// lastTimeToken returns the finest token of a format
+func lastTimeToken(format string) string {
+	last := ""
+	for _, token := range timeTokens {
+		if strings.Contains(format, token.Token) {
+			last = token.Token
+		}
+	}
+	return last
+}
+
+// allMatchTime reports whether every value parses with the strftime format.
+// Years must lie between 1970 and 2100 so that other numbers are not mistaken
+// for years.
+func allMatchTime(values []string, format string) bool {
+	layout := timeLayout(format)
+	for _, value := range values {
+		t, err := time.Parse(layout, value)
+		if err != nil || len(value) != len(layout) {
+			return false
+		}
+		if strings.Contains(format, "%Y") && (t.Year() < 1970 || t.Year() > 2100) {
+			return false
+		}
+	}
+	return true
+}
+
+// timeLayout converts a strftime format to a Go time layout
+func timeLayout(format string) string {
+	for _, token := range timeTokens {
+		format = strings.ReplaceAll(format, token.Token, token.Layout)
+	}
+	return format
+}
+
+// timeTemplate returns the Cribl expression for a time format
+func timeTemplate(format string) string {
+	return "${_time:" + format + "}"
+}
+
+// variableName names a segment whose values vary
+func variableName(i int, values []string) string {
+	accounts, regions := true, true
+	for _, value := range values {
+		accounts = accounts && accountIDPattern.MatchString(value)
+		regions = regions && regionPattern.MatchString(value)
+	}
+	switch {
+	case accounts:
+		return "accountId"
+	case regions:
+		return "region"
+	default:
+		return fmt.Sprintf("part%d", i+1)
+	}
+}
+
+// distinctExamples returns up to maxSegmentExamples distinct values
+func distinctExamples(values []string) []string {
+	var examples []string
+	seen := make(map[string]bool)
+	for _, value := range values {
+		if seen[value] {
+			continue
+		}
+		seen[value] = true
+		if len(examples) < maxSegmentExamples {
+			examples = append(examples, value)
+		}
+	}
+	if len(seen) == 1 {
+		return examples
+	}
+	sort.Strings(examples)
+	return examples
+}
+
+// timeCoverage returns the earliest and latest time of the sampled paths and
+// the finest time token found
+func timeCoverage(segments []PartitionSegment, paths [][]string) (*time.Time, *time.Time, string) {
+	granularity := ""
+	for _, segment := range segments {
+		if token := lastTimeToken(segment.Format); token != "" && strings.Index(tokenOrder(), token) > strings.Index(tokenOrder(), granularity) {
+			granularity = token
+		}
+	}
+	if granularity == "" || !hasYear(segments) {
+		return nil, nil, ""
+	}
+
+	var earliest, latest *time.Time
+	for _, path := range paths {
+		t, ok := pathTime(segments, path)
+		if !ok {
+			continue
+		}
+		if earliest == nil || t.Before(*earliest) {
+			earliest = &t
+		}
+		if latest == nil || t.After(*latest) {
+			latest = &t
+		}
+	}
+	return earliest, latest, granularity
+}
+
+func tokenOrder() string {
+	var order strings.Builder
+	for _, token := range timeTokens {
+		order.WriteString(token.Token)
+	}
+	return order.String()
+}
+
+func hasYear(segments []PartitionSegment) bool {
+	for _, segment := range segments {
+		if strings.Contains(segment.Format, "%Y") {
+			return true
+		}
+	}
+	return false
+}
+
+// pathTime assembles the time of a path from its time segments
+func pathTime(segments []PartitionSegment, path []string) (time.Time, bool) {
+	year, month, day, hour, minute := 0, 1, 1, 0, 0
+	for i, segment := range segments {
+		if segment.Format == "" {
+			continue
+		}
+		value := path[i]
+		if segment.Kind == SegmentHive {
+			value = strings.SplitN(value, "=", 2)[1]
+		}
+		t, err := time.Parse(timeLayout(segment.Format), value)
+		if err != nil {
+			return time.Time{}, false
+		}
+		if strings.Contains(segment.Format, "%Y") {
+			year = t.Year()
+		}
+		if strings.Contains(segment.Format, "%m") {
+			month = int(t.Month())
+		}
+		if strings.Contains(segment.Format, "%d") {
+			day = t.Day()
+		}
+		if strings.Contains(segment.Format, "%H") {
+			hour = t.Hour()
+		}
+		if strings.Contains(segment.Format, "%M") {
+			minute = t.Minute()
+		}
+	}
+	return time.Date(year, time.Month(month), day, hour, minute, 0, 0, time.UTC), true
+}
+
+// PrintText prints the inferred layout in a human-readable format
+func (l *PartitionLayout) PrintText() {
+	fmt.Printf("Partition layout of s3://%s/%s (%d of %d sampled keys matched):\n", l.Bucket, l.Prefix, l.MatchedKeys, l.SampledKeys)
+	for i, segment := range l.Segments {
+		fmt.Printf("  %d. %-8s %-24s e.g. %s\n", i+1, segment.Kind, segment.Template, strings.Join(segment.Examples, ", "))
+	}
+	if len(l.Segments) == 0 {
+		fmt.Println("  objects are stored directly under the prefix, no partitions found")
+	}
+
+	if l.Earliest != nil && l.Latest != nil {
+		layout := timeLayout(granularityFormat(l.Granularity))
+		to := "to"
+		if l.LatestIsLowerBound {
+			to = "to at least"
+		}
+		fmt.Printf("\nTime coverage: %s %s %s (%s partitions)\n", l.Earliest.Format(layout), to, l.Latest.Format(layout), granularityName(l.Granularity))
+	} else {
+		fmt.Println("\nTime coverage: no time partitions found")
+	}
+
+	fmt.Printf("\nPartition template:  %s\n", l.Template)
+	fmt.Printf("Cribl Search path:   %s\n", l.SearchPath)
+}
+
+// PrintJSON prints the inferred layout in JSON format
+func (l *PartitionLayout) PrintJSON() error {
+	jsonData, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(jsonData))
+	return nil
+}
+
+// granularityFormat returns the format showing a time down to token
+func granularityFormat(token string) string {
+	switch token {
+	case "%Y":
+		return "%Y"
+	case "%m":
+		return "%Y-%m"
+	case "%d":
+		return "%Y-%m-%d"
+	case "%H":
+		return "%Y-%m-%d %H:00"
+	default:
+		return "%Y-%m-%d %H:%M"
+	}
+}
+
+func granularityName(token string) string {
+	switch token {
+	case "%Y":
+		return "yearly"
+	case "%m":
+		return "monthly"
+	case "%d":
+		return "daily"
+	case "%H":
+		return "hourly"
+	default:
+		return "minutely"
+	}
+}
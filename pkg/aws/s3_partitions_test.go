@@ -0,0 +1,227 @@
+// pkg/aws/s3_partitions_test.go
+package aws
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInferPartitionLayout(t *testing.T) {
+	tests := []struct {
+		name        string
+		prefix      string
+		keys        []string
+		template    string
+		granularity string
+		earliest    string
+		latest      string
+	}{
+		{
+			name:   "cloudtrail",
+			prefix: "AWSLogs/",
+			keys: []string{
+				"AWSLogs/123456789012/CloudTrail/us-east-1/2024/05/01/a.json.gz",
+				"AWSLogs/123456789012/CloudTrail/us-west-2/2024/05/02/b.json.gz",
+				"AWSLogs/123456789012/CloudTrail/us-east-1/2024/06/12/c.json.gz",
+			},
+			template:    "123456789012/CloudTrail/${region}/${_time:%Y}/${_time:%m}/${_time:%d}/",
+			granularity: "%d",
+			earliest:    "2024-05-01T00:00:00Z",
+			latest:      "2024-06-12T00:00:00Z",
+		},
+		{
+			name:   "hive",
+			prefix: "data/",
+			keys: []string{
+				"data/year=2024/month=05/day=01/hour=03/a.gz",
+				"data/year=2024/month=05/day=02/hour=17/b.gz",
+			},
+			template:    "year=${_time:%Y}/month=${_time:%m}/day=${_time:%d}/hour=${_time:%H}/",
+			granularity: "%H",
+			earliest:    "2024-05-01T03:00:00Z",
+			latest:      "2024-05-02T17:00:00Z",
+		},
+		{
+			name:   "hive with variable key",
+			prefix: "data/",
+			keys: []string{
+				"data/host=web1/dt=2024-05-01/a.gz",
+				"data/host=web2/dt=2024-05-03/b.gz",
+			},
+			template:    "host=${host}/dt=${_time:%Y-%m-%d}/",
+			granularity: "%d",
+			earliest:    "2024-05-01T00:00:00Z",
+			latest:      "2024-05-03T00:00:00Z",
+		},
+		{
+			name:   "prefix cut back to slash",
+			prefix: "logs",
+			keys: []string{
+				"logs/2024-05-01T03/a.gz",
+				"logs/2024-05-01T04/b.gz",
+			},
+			template:    "logs/${_time:%Y-%m-%dT%H}/",
+			granularity: "%H",
+			earliest:    "2024-05-01T03:00:00Z",
+			latest:      "2024-05-01T04:00:00Z",
+		},
+		{
+			name:   "most common depth wins",
+			prefix: "",
+			keys: []string{
+				"README.txt",
+				"2024/05/a.gz",
+				"2024/06/b.gz",
+			},
+			template:    "${_time:%Y}/${_time:%m}/",
+			granularity: "%m",
+			earliest:    "2024-05-01T00:00:00Z",
+			latest:      "2024-06-01T00:00:00Z",
+		},
+		{
+			name:   "no time",
+			prefix: "exports/",
+			keys: []string{
+				"exports/team-a/a.csv",
+				"exports/team-b/b.csv",
+			},
+			template: "${part1}/",
+		},
+		{
+			name:   "flat",
+			prefix: "flat/",
+			keys:   []string{"flat/a.gz", "flat/b.gz"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout := InferPartitionLayout(tt.prefix, tt.keys)
+			if layout.Template != tt.template {
+				t.Errorf("got template %q, want %q", layout.Template, tt.template)
+			}
+			if layout.Granularity != tt.granularity {
+				t.Errorf("got granularity %q, want %q", layout.Granularity, tt.granularity)
+			}
+			if got := formatTime(layout.Earliest); got != tt.earliest {
+				t.Errorf("got earliest %q, want %q", got, tt.earliest)
+			}
+			if got := formatTime(layout.Latest); got != tt.latest {
+				t.Errorf("got latest %q, want %q", got, tt.latest)
+			}
+		})
+	}
+}
+
+func TestClassifySegment(t *testing.T) {
+	tests := []struct {
+		name          string
+		values        []string
+		previousToken string
+		kind          string
+		template      string
+		format        string
+	}{
+		{
+			name:     "year",
+			values:   []string{"2023", "2024"},
+			kind:     SegmentTime,
+			template: "${_time:%Y}",
+			format:   "%Y",
+		},
+		{
+			name:          "month after year",
+			values:        []string{"05", "12"},
+			previousToken: "%Y",
+			kind:          SegmentTime,
+			template:      "${_time:%m}",
+			format:        "%m",
+		},
+		{
+			name:     "month without year",
+			values:   []string{"05", "12"},
+			kind:     SegmentVariable,
+			template: "${part1}",
+		},
+		{
+			name:     "year outside range",
+			values:   []string{"1234", "5678"},
+			kind:     SegmentVariable,
+			template: "${part1}",
+		},
+		{
+			name:     "date",
+			values:   []string{"2024-05-01", "2024-05-02"},
+			kind:     SegmentTime,
+			template: "${_time:%Y-%m-%d}",
+			format:   "%Y-%m-%d",
+		},
+		{
+			name:     "invalid date",
+			values:   []string{"2024-13-01"},
+			kind:     SegmentLiteral,
+			template: "2024-13-01",
+		},
+		{
+			name:     "compact hour",
+			values:   []string{"2024050103"},
+			kind:     SegmentTime,
+			template: "${_time:%Y%m%d%H}",
+			format:   "%Y%m%d%H",
+		},
+		{
+			name:     "hive time key",
+			values:   []string{"year=2024"},
+			kind:     SegmentHive,
+			template: "year=${_time:%Y}",
+			format:   "%Y",
+		},
+		{
+			name:     "hive variable",
+			values:   []string{"host=web1", "host=web2"},
+			kind:     SegmentHive,
+			template: "host=${host}",
+		},
+		{
+			name:     "mixed hive keys",
+			values:   []string{"host=web1", "zone=a"},
+			kind:     SegmentVariable,
+			template: "${part1}",
+		},
+		{
+			name:     "literal",
+			values:   []string{"CloudTrail", "CloudTrail"},
+			kind:     SegmentLiteral,
+			template: "CloudTrail",
+		},
+		{
+			name:     "accounts",
+			values:   []string{"123456789012", "210987654321"},
+			kind:     SegmentVariable,
+			template: "${accountId}",
+		},
+		{
+			name:     "regions",
+			values:   []string{"us-east-1", "us-gov-west-1"},
+			kind:     SegmentVariable,
+			template: "${region}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segment := classifySegment(0, tt.values, tt.previousToken)
+			if segment.Kind != tt.kind || segment.Template != tt.template || segment.Format != tt.format {
+				t.Errorf("got kind %q, template %q, format %q; want %q, %q, %q",
+					segment.Kind, segment.Template, segment.Format, tt.kind, tt.template, tt.format)
+			}
+		})
+	}
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
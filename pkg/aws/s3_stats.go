@@ -0,0 +1,278 @@
+// pkg/aws/s3_stats.go
+package aws
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// S3 storage metrics published daily to CloudWatch
+const (
+	s3MetricsNamespace     = "AWS/S3"
+	metricBucketSizeBytes  = "BucketSizeBytes"
+	metricNumberOfObjects  = "NumberOfObjects"
+	allStorageTypes        = "AllStorageTypes"
+	storageMetricsPeriod   = 86400
+	storageMetricsLookback = 3 * 24 * time.Hour
+)
+
+// BucketStats is the size and object count of a bucket from its CloudWatch
+// storage metrics, which S3 updates once a day
+type BucketStats struct {
+	Bucket         string              `json:"bucket"`
+	Region         string              `json:"region"`
+	SizeBytes      int64               `json:"size_bytes"`
+	ObjectCount    int64               `json:"object_count"`
+	StorageClasses []StorageClassStats `json:"storage_classes"`
+	Error          string              `json:"error,omitempty"`
+}
+
+// StorageClassStats is the size of the objects in one storage type, such as
+// StandardStorage or GlacierStorage
+type StorageClassStats struct {
+	StorageClass string `json:"storage_class"`
+	SizeBytes    int64  `json:"size_bytes"`
+}
+
+// StatsClient reads bucket storage metrics from CloudWatch
+type StatsClient struct {
+	S3         *S3Client
+	CloudWatch *cloudwatch.Client
+}
+
+// NewStatsClient initializes a new stats client
+func NewStatsClient(cfg aws.Config) *StatsClient {
+	return &StatsClient{
+		S3:         NewS3Client(cfg),
+		CloudWatch: cloudwatch.NewFromConfig(cfg),
+	}
+}
+
+// GetBucketStats returns the storage metrics of each bucket, reading up to
+// concurrency buckets at once. A bucket whose metrics cannot be read has
+// Error set instead of failing the whole report.
+func (c *StatsClient) GetBucketStats(buckets []Bucket, concurrency int) []BucketStats {
+	if concurrency < 1 {
+		concurrency = DefaultDetailsConcurrency
+	}
+
+	stats := make([]BucketStats, len(buckets))
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	for i := 0; i < min(concurrency, len(buckets)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				stats[j] = c.getBucketStats(buckets[j])
+			}
+		}()
+	}
+	for i := range buckets {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return stats
+}
+
+// getBucketStats lists the storage metrics of a bucket in its region and reads
+// the latest daily value of each
+func (c *StatsClient) getBucketStats(bucket Bucket) BucketStats {
+	stats := BucketStats{Bucket: bucket.Name, Region: bucket.Region, StorageClasses: []StorageClassStats{}}
+	if stats.Region == "" {
+		region, err := c.S3.GetBucketRegion(bucket.Name)
+		if err != nil {
+			stats.Error = err.Error()
+			return stats
+		}
+		stats.Region = region
+	}
+	inRegion := func(o *cloudwatch.Options) {
+		o.Region = stats.Region
+	}
+
+	var metrics []cwtypes.Metric
+	paginator := cloudwatch.NewListMetricsPaginator(c.CloudWatch, &cloudwatch.ListMetricsInput{
+		Namespace: aws.String(s3MetricsNamespace),
+		Dimensions: []cwtypes.DimensionFilter{
+			{Name: aws.String("BucketName"), Value: aws.String(bucket.Name)},
+		},
+	}, func(o *cloudwatch.ListMetricsPaginatorOptions) {
+		o.StopOnDuplicateToken = true
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO(), inRegion)
+		if err != nil {
+			stats.Error = fmt.Sprintf("failed to list metrics of bucket '%s': %v", bucket.Name, err)
+			return stats
+		}
+		for _, metric := range page.Metrics {
+			name := aws.ToString(metric.MetricName)
+			if name == metricBucketSizeBytes || name == metricNumberOfObjects {
+				metrics = append(metrics, metric)
+			}
+		}
+	}
+	if len(metrics) == 0 {
+		return stats
+	}
+
+	queries := make([]cwtypes.MetricDataQuery, 0, len(metrics))
+	for i := range metrics {
+		queries = append(queries, cwtypes.MetricDataQuery{
+			Id: aws.String(fmt.Sprintf("m%d", i)),
+			MetricStat: &cwtypes.MetricStat{
+				Metric: &metrics[i],
+				Period: aws.Int32(storageMetricsPeriod),
+				Stat:   aws.String("Average"),
+			},
+		})
+	}
+
+	end := time.Now()
+	values := make(map[string]float64)
+	dataPaginator := cloudwatch.NewGetMetricDataPaginator(c.CloudWatch, &cloudwatch.GetMetricDataInput{
+		MetricDataQueries: queries,
+		StartTime:         aws.Time(end.Add(-storageMetricsLookback)),
+		EndTime:           aws.Time(end),
+		ScanBy:            cwtypes.ScanByTimestampDescending,
+	})
+	for dataPaginator.HasMorePages() {
+		page, err := dataPaginator.NextPage(context.TODO(), inRegion)
+		if err != nil {
+			stats.Error = fmt.Sprintf("failed to get metrics of bucket '%s': %v", bucket.Name, err)
+			return stats
+		}
+		for _, result := range page.MetricDataResults {
+			id := aws.ToString(result.Id)
+			// Values are newest first, keep the latest datapoint
+			if _, ok := values[id]; !ok && len(result.Values) > 0 {
+				values[id] = result.Values[0]
+			}
+		}
+	}
+
+	for i, metric := range metrics {
+		value, ok := values[fmt.Sprintf("m%d", i)]
+		if !ok {
+			continue
+		}
+		storageType := metricDimension(metric, "StorageType")
+		switch aws.ToString(metric.MetricName) {
+		case metricNumberOfObjects:
+			if storageType == allStorageTypes {
+				stats.ObjectCount = int64(value)
+			}
+		case metricBucketSizeBytes:
+			stats.SizeBytes += int64(value)
+			stats.StorageClasses = append(stats.StorageClasses, StorageClassStats{
+				StorageClass: storageType,
+				SizeBytes:    int64(value),
+			})
+		}
+	}
+	sort.Slice(stats.StorageClasses, func(i, j int) bool {
+		return stats.StorageClasses[i].SizeBytes > stats.StorageClasses[j].SizeBytes
+	})
+	return stats
+}
+
+// metricDimension returns the value of a dimension of a metric
+func metricDimension(metric cwtypes.Metric, name string) string {
+	for _, dimension := range metric.Dimensions {
+		if aws.ToString(dimension.Name) == name {
+			return aws.ToString(dimension.Value)
+		}
+	}
+	return ""
+}
+
+// FormatBytes returns a size in bytes in binary units, such as 1.5 GiB
+func FormatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// PrintBucketStatsText prints the size and object count of each bucket with
+// its storage classes below it, followed by the totals
+func PrintBucketStatsText(stats []BucketStats) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "BUCKET\tREGION\tSIZE\tOBJECTS")
+	var totalSize, totalObjects int64
+	for _, s := range stats {
+		totalSize += s.SizeBytes
+		totalObjects += s.ObjectCount
+		if s.Error != "" {
+			fmt.Fprintf(w, "%s\t%s\t-\t-\n", s.Bucket, valueOrDash(s.Region))
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", s.Bucket, s.Region, FormatBytes(s.SizeBytes), s.ObjectCount)
+		for _, class := range s.StorageClasses {
+			fmt.Fprintf(w, "  %s\t\t%s\t\n", class.StorageClass, FormatBytes(class.SizeBytes))
+		}
+	}
+	fmt.Fprintf(w, "TOTAL\t\t%s\t%d\n", FormatBytes(totalSize), totalObjects)
+	w.Flush()
+
+	for _, s := range stats {
+		if s.Error != "" {
+			fmt.Printf("! %s: %s\n", s.Bucket, s.Error)
+		}
+	}
+	fmt.Println("Storage metrics are updated by S3 once a day.")
+}
+
+// PrintBucketStatsJSON prints the bucket stats in JSON format
+func PrintBucketStatsJSON(stats []BucketStats) error {
+	jsonData, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(jsonData))
+	return nil
+}
+
+// PrintBucketStatsCSV prints one row per bucket with the storage class
+// AllStorageTypes, followed by one row per storage class
+func PrintBucketStatsCSV(stats []BucketStats) error {
+	w := csv.NewWriter(os.Stdout)
+	if err := w.Write([]string{"bucket", "region", "storage_class", "size_bytes", "object_count", "error"}); err != nil {
+		return err
+	}
+	for _, s := range stats {
+		record := []string{s.Bucket, s.Region, allStorageTypes,
+			strconv.FormatInt(s.SizeBytes, 10), strconv.FormatInt(s.ObjectCount, 10), s.Error}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+		for _, class := range s.StorageClasses {
+			record := []string{s.Bucket, s.Region, class.StorageClass, strconv.FormatInt(class.SizeBytes, 10), "", ""}
+			if err := w.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,168 @@
+// pkg/aws/tags.go
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// Tags applied automatically to roles and policies created by iam setup. Tags
+// with the cribl: prefix are owned by this tool and reconciled on every run.
+const (
+	TagPrefix      = "cribl:"
+	TagWorkspace   = TagPrefix + "workspace"
+	TagWorkergroup = TagPrefix + "workergroup"
+	TagAction      = TagPrefix + "action"
+	TagToolVersion = TagPrefix + "tool-version"
+)
+
+// IAM tag limits
+const (
+	maxTagsPerResource = 50
+	maxTagKeyLength    = 128
+	maxTagValueLength  = 256
+)
+
+// ParseTag parses a "key=value" tag
+func ParseTag(entry string) (key, value string, err error) {
+	key, value, ok := strings.Cut(entry, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return "", "", fmt.Errorf("invalid tag '%s', expected key=value", entry)
+	}
+	return key, strings.TrimSpace(value), nil
+}
+
+// validateTags checks user tags against the IAM tag limits and reserved keys
+func validateTags(tags map[string]string) error {
+	// Leave room for the automatic tags
+	if len(tags) > maxTagsPerResource-5 {
+		return fmt.Errorf("too many tags, at most %d can be given", maxTagsPerResource-5)
+	}
+	for key, value := range tags {
+		switch {
+		case strings.HasPrefix(strings.ToLower(key), "aws:"):
+			return fmt.Errorf("invalid tag '%s': the aws: prefix is reserved", key)
+		case strings.HasPrefix(key, TagPrefix) || key == ManagedByTagKey:
+			return fmt.Errorf("invalid tag '%s': the key is set automatically", key)
+		case len(key) > maxTagKeyLength:
+			return fmt.Errorf("invalid tag '%s': keys are limited to %d characters", key, maxTagKeyLength)
+		case len(value) > maxTagValueLength:
+			return fmt.Errorf("invalid tag '%s': values are limited to %d characters", key, maxTagValueLength)
+		}
+	}
+	return nil
+}
+
+// setupTags returns the user tags from opts together with the automatic
+// ownership tags. Workspaces and worker groups of several principals are
+// joined with spaces, since IAM tag values cannot contain commas.
+func setupTags(opts SetupOptions) map[string]string {
+	tags := make(map[string]string, len(opts.Tags)+5)
+	for key, value := range opts.Tags {
+		tags[key] = value
+	}
+
+	var workspaces, workergroups []string
+	for _, principal := range opts.Principals {
+		workspaces = appendUniqueStrings(workspaces, principal.Workspace)
+		// Search principals are the workspace's search-exec role, without a worker group
+		if principal.Workergroup != "" && !principal.SearchExec && opts.Action != ActionSearch {
+			workergroups = appendUniqueStrings(workergroups, principal.Workergroup)
+		}
+	}
+
+	tags[ManagedByTagKey] = ManagedByTagValue
+	tags[TagAction] = opts.Action
+	if len(workspaces) > 0 {
+		tags[TagWorkspace] = strings.Join(workspaces, " ")
+	}
+	if len(workergroups) > 0 {
+		tags[TagWorkergroup] = strings.Join(workergroups, " ")
+	}
+	if opts.ToolVersion != "" {
+		tags[TagToolVersion] = opts.ToolVersion
+	}
+	return tags
+}
+
+// iamTags converts a tag map to IAM tags sorted by key
+func iamTags(tags map[string]string) []types.Tag {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]types.Tag, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, types.Tag{Key: aws.String(key), Value: aws.String(tags[key])})
+	}
+	return result
+}
+
+// reconcileRoleTags applies tags to an existing role and removes automatic
+// tags that no longer apply. User tags that were removed from the command line
+// are left in place, since the tool cannot tell them apart from tags set by others.
+func (c *IAMClient) reconcileRoleTags(roleName string, tags map[string]string) error {
+	logger := c.logger.With().Str("role_name", roleName).Logger()
+
+	_, err := c.Client.TagRole(context.TODO(), &iam.TagRoleInput{
+		RoleName: aws.String(roleName),
+		Tags:     iamTags(tags),
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to tag IAM role")
+		return fmt.Errorf("failed to tag IAM role '%s': %w", roleName, err)
+	}
+
+	out, err := c.Client.ListRoleTags(context.TODO(), &iam.ListRoleTagsInput{
+		RoleName: aws.String(roleName),
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to list role tags")
+		return fmt.Errorf("failed to list tags of IAM role '%s': %w", roleName, err)
+	}
+	var stale []string
+	for _, tag := range out.Tags {
+		key := aws.ToString(tag.Key)
+		if _, ok := tags[key]; !ok && strings.HasPrefix(key, TagPrefix) {
+			stale = append(stale, key)
+		}
+	}
+	if len(stale) > 0 {
+		_, err := c.Client.UntagRole(context.TODO(), &iam.UntagRoleInput{
+			RoleName: aws.String(roleName),
+			TagKeys:  stale,
+		})
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to untag IAM role")
+			return fmt.Errorf("failed to remove stale tags from IAM role '%s': %w", roleName, err)
+		}
+	}
+
+	logger.Info().Int("tag_count", len(tags)).Strs("removed", stale).Msg("reconciled role tags")
+	return nil
+}
+
+// tagManagedPolicy applies tags to an existing managed policy
+func (c *IAMClient) tagManagedPolicy(policyARN string, tags map[string]string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	_, err := c.Client.TagPolicy(context.TODO(), &iam.TagPolicyInput{
+		PolicyArn: aws.String(policyARN),
+		Tags:      iamTags(tags),
+	})
+	if err != nil {
+		c.logger.Error().Err(err).Str("policy_arn", policyARN).Msg("failed to tag managed policy")
+		return fmt.Errorf("failed to tag managed policy '%s': %w", policyARN, err)
+	}
+	return nil
+}